@@ -0,0 +1,80 @@
+// Filename: cmd/api/context.go
+
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/jsonlog"
+)
+
+// Define a custom type for our context keys
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// contextSetUser() returns a copy of the request with the user added to its context
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser() retrieves the user from the request context
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
+
+// The route pattern is only known once the router has picked a handler, which
+// happens after the outer middleware has added it to the context. So the
+// context holds a pointer that the handler fills in
+const routeContextKey = contextKey("route")
+
+// contextSetRoute() returns a copy of the request with an empty route pattern
+// added to its context, along with the pointer to that pattern
+func (app *application) contextSetRoute(r *http.Request) (*http.Request, *string) {
+	route := new(string)
+	ctx := context.WithValue(r.Context(), routeContextKey, route)
+	return r.WithContext(ctx), route
+}
+
+// contextSetRoutePattern() records the route pattern that matched the request
+func (app *application) contextSetRoutePattern(r *http.Request, pattern string) {
+	if route, ok := r.Context().Value(routeContextKey).(*string); ok {
+		*route = pattern
+	}
+}
+
+// contextGetRoutePattern() retrieves the route pattern that matched the
+// request, which is empty when no route matched
+func (app *application) contextGetRoutePattern(r *http.Request) string {
+	route, ok := r.Context().Value(routeContextKey).(*string)
+	if !ok {
+		return ""
+	}
+	return *route
+}
+
+const requestIDContextKey = contextKey("request_id")
+
+// contextSetRequestID() returns a copy of the request with the request ID
+// added to its context. The ID is also added to the logging properties of the
+// context so every entry logged through app.logger.WithContext() carries it
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	ctx = jsonlog.NewContext(ctx, map[string]interface{}{"request_id": id})
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID() retrieves the request ID from the request context,
+// which is empty for requests that did not go through the requestID()
+// middleware
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
@@ -0,0 +1,241 @@
+// Filename: cmd/api/export.go
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/xlsx"
+)
+
+// The listing formats and their media types
+var exportFormats = map[string]string{
+	"json":   "application/json",
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   xlsx.ContentType,
+}
+
+// The negotiateFormat() method picks the listing format from the "format"
+// query string parameter, falling back to the Accept header and then JSON
+func (app *application) negotiateFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+		for format, contentType := range exportFormats {
+			if mediaType == contentType {
+				return format
+			}
+		}
+	}
+	return "json"
+}
+
+// exportSchools() streams every school that matches the search in the
+// requested format. The query and its first row are run before anything is
+// written, so those errors still get a 500. Errors after that can no longer
+// change the status code, so they are only logged
+func (app *application) exportSchools(w http.ResponseWriter, r *http.Request, format string, search data.SchoolSearch, filters data.Filters) {
+	done, ok := app.beginExport(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	rows, err := app.models.Schools.Export(search, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer rows.Close()
+	// Large exports take longer than the server's WriteTimeout
+	err = app.extendWriteDeadline(w, data.ExportTimeout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", exportFormats[format])
+	if format != "ndjson" {
+		w.Header().Set("Content-Disposition", `attachment; filename="schools.`+format+`"`)
+	}
+	switch format {
+	case "csv":
+		err = app.exportSchoolsCSV(w, rows)
+	case "ndjson":
+		err = app.exportSchoolsNDJSON(w, rows)
+	case "xlsx":
+		err = app.exportSchoolsXLSX(w, rows)
+	}
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// The beginExport() method checks that the user may export schools and takes
+// one of the export slots. An export holds a database connection for as long
+// as the client keeps reading, so only a few may run at once. It sends the
+// error response and returns false on failure, otherwise the returned
+// function gives the slot back
+func (app *application) beginExport(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	if !app.authorize(w, r, "schools:read") {
+		return nil, false
+	}
+	if app.exportSlots == nil {
+		return func() {}, true
+	}
+	select {
+	case app.exportSlots <- struct{}{}:
+		return func() { <-app.exportSlots }, true
+	default:
+		app.exportsBusyResponse(w, r)
+		return nil, false
+	}
+}
+
+// The columns of the CSV and XLSX exports. The mode column is a semicolon
+// separated list so the CSV can be fed back into the import endpoint, which
+// ignores the id and version columns
+var exportColumns = []string{"id", "name", "level", "contact", "phone", "email", "website", "address", "mode", "latitude", "longitude", "version"}
+
+// schoolIterator is the part of *data.SchoolRows the export writers use, so
+// they can also be given schools that do not come from the database
+type schoolIterator interface {
+	Next() bool
+	School() *data.School
+	Err() error
+}
+
+// The exportRecord() function returns the text columns of a school for the
+// CSV and XLSX exports, made safe to open in a spreadsheet
+func exportRecord(school *data.School) []string {
+	return []string{
+		spreadsheetSafe(school.Name),
+		spreadsheetSafe(school.Level),
+		spreadsheetSafe(school.Contact),
+		spreadsheetSafe(school.Phone),
+		spreadsheetSafe(school.Email),
+		spreadsheetSafe(school.Website),
+		spreadsheetSafe(school.Address),
+		spreadsheetSafe(strings.Join(school.Mode, ";")),
+	}
+}
+
+func (app *application) exportSchoolsCSV(w io.Writer, rows schoolIterator) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(exportColumns)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		school := rows.School()
+		record := []string{strconv.FormatInt(school.ID, 10)}
+		record = append(record, exportRecord(school)...)
+		record = append(record,
+			formatCoordinate(school.Latitude),
+			formatCoordinate(school.Longitude),
+			strconv.FormatInt(int64(school.Version), 10),
+		)
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (app *application) exportSchoolsNDJSON(w io.Writer, rows schoolIterator) error {
+	// Encode() writes one JSON value per line
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		err := enc.Encode(rows.School())
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (app *application) exportSchoolsXLSX(w io.Writer, rows schoolIterator) error {
+	xw, err := xlsx.NewWriter(w, "Schools")
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	err = xw.WriteRow(header...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		school := rows.School()
+		cells := []interface{}{school.ID}
+		for _, text := range exportRecord(school) {
+			cells = append(cells, text)
+		}
+		cells = append(cells,
+			coordinateCell(school.Latitude),
+			coordinateCell(school.Longitude),
+			school.Version,
+		)
+		err = xw.WriteRow(cells...)
+		if err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return xw.Close()
+}
+
+// Spreadsheets treat text starting with one of these as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// The spreadsheetSafe() function prefixes text that a spreadsheet would run
+// as a formula with a quote, so it is shown as text instead. The import
+// endpoint strips the quote again
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// The spreadsheetUnsafe() function undoes spreadsheetSafe()
+func spreadsheetUnsafe(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// The formatCoordinate() function formats an optional latitude or longitude,
+// using an empty string for a missing value
+func formatCoordinate(coordinate *float64) string {
+	if coordinate == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*coordinate, 'f', -1, 64)
+}
+
+// The coordinateCell() function returns a spreadsheet cell for an optional
+// latitude or longitude
+func coordinateCell(coordinate *float64) interface{} {
+	if coordinate == nil {
+		return ""
+	}
+	return *coordinate
+}
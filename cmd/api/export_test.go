@@ -0,0 +1,108 @@
+// Filename: cmd/api/export_test.go
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"schools.federicorosado.net/internal/data"
+)
+
+func TestSpreadsheetSafe(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Belize High School", "Belize High School"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+501-223-4567", "'+501-223-4567"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"'quoted", "'quoted"},
+	}
+	for _, tt := range tests {
+		got := spreadsheetSafe(tt.in)
+		if got != tt.want {
+			t.Errorf("spreadsheetSafe(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+		// The import endpoint must get the original value back
+		if back := spreadsheetUnsafe(got); back != tt.in {
+			t.Errorf("spreadsheetUnsafe(%q) = %q; want %q", got, back, tt.in)
+		}
+	}
+}
+
+// sliceRows iterates over schools that are already in memory
+type sliceRows struct {
+	schools []*data.School
+	next    int
+}
+
+func (s *sliceRows) Next() bool {
+	s.next++
+	return s.next <= len(s.schools)
+}
+
+func (s *sliceRows) School() *data.School { return s.schools[s.next-1] }
+
+func (s *sliceRows) Err() error { return nil }
+
+// A CSV export can be imported again without changes
+func TestExportCSVRoundTrip(t *testing.T) {
+	lat, lng := 17.2510, -88.7590
+	schools := []*data.School{
+		{
+			ID:        7,
+			Name:      "=HYPERLINK(\"http://evil\")",
+			Level:     "Secondary",
+			Contact:   "Jane Doe",
+			Phone:     "+501-223-4567",
+			Email:     "info@bhs.edu.bz",
+			Website:   "https://bhs.edu.bz",
+			Address:   "12 \"A\" Street, Belmopan",
+			Mode:      []string{"in-person", "online"},
+			Latitude:  &lat,
+			Longitude: &lng,
+			Version:   3,
+		},
+		{
+			ID:      8,
+			Name:    "@Home Academy",
+			Level:   "Primary",
+			Contact: "John Smith",
+			Phone:   "501-822-1234",
+			Email:   "office@home.edu.bz",
+			Website: "http://home.edu.bz/about",
+			Address: "Mile 3, George Price Highway",
+			Mode:    []string{"blended"},
+			Version: 1,
+		},
+	}
+	app := &application{}
+	var buf bytes.Buffer
+	err := app.exportSchoolsCSV(&buf, &sliceRows{schools: schools})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, rejected, err := readSchoolsCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 0 {
+		t.Fatalf("got rejected records %v", rejected)
+	}
+	if len(imported) != len(schools) {
+		t.Fatalf("imported %d schools; want %d", len(imported), len(schools))
+	}
+	for i, school := range imported {
+		// The id and version are not imported
+		want := *schools[i]
+		want.ID, want.Version = 0, 0
+		if !reflect.DeepEqual(*school, want) {
+			t.Errorf("imported %+v; want %+v", *school, want)
+		}
+	}
+}
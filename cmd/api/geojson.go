@@ -0,0 +1,225 @@
+// Filename: cmd/api/geojson.go
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// The GeoJSON types we read and write
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string           `json:"type"`
+	ID         int64            `json:"id,omitempty"`
+	Geometry   *geoJSONGeometry `json:"geometry"`
+	Properties json.RawMessage  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// geoJSONProperties holds the school fields of a feature
+type geoJSONProperties struct {
+	Name    string   `json:"name"`
+	Level   string   `json:"level"`
+	Contact string   `json:"contact"`
+	Phone   string   `json:"phone"`
+	Email   string   `json:"email,omitempty"`
+	Website string   `json:"website,omitempty"`
+	Address string   `json:"address"`
+	Mode    []string `json:"mode"`
+	Version int32    `json:"version,omitempty"`
+}
+
+// listSchoolsGeoJSONHandler for the "GET" /v1/schools.geojson endpoint.
+// It takes the same filters as listSchoolHandler and streams a
+// FeatureCollection of every matching school that has a location
+func (app *application) listSchoolsGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.SchoolSearch
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.SchoolSearch = app.readSchoolSearch(qs, v)
+	// The whole collection is returned so there is no paging
+	input.Filters.Page = 1
+	input.Filters.PageSize = 20
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortList = schoolSortList
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Only admins can see soft deleted schools
+	if input.IncludeDeleted && !app.authorizeIncludeDeleted(w, r) {
+		return
+	}
+	// The whole collection is streamed, so it has the same limits as exports
+	done, ok := app.beginExport(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	// Run the query and fetch the first row before anything is written, so a
+	// failing query still gets a 500
+	rows, err := app.models.Schools.Export(input.SchoolSearch, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer rows.Close()
+	// Large collections take longer than the server's WriteTimeout
+	err = app.extendWriteDeadline(w, data.ExportTimeout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"type":"FeatureCollection","features":[`)
+	first := true
+	for rows.Next() {
+		school := rows.School()
+		// Schools without a location can't be placed on a map
+		if school.Latitude == nil || school.Longitude == nil {
+			continue
+		}
+		feature, err := geoJSONFeatureFor(school)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		_, err = bw.Write(feature)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+	if err = rows.Err(); err != nil {
+		// The status code has already been sent so all we can do is log
+		app.logError(r, err)
+		return
+	}
+	bw.WriteString("]}\n")
+	err = bw.Flush()
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// The geoJSONFeatureFor() function encodes a school that has a location as a
+// Point feature
+func geoJSONFeatureFor(school *data.School) ([]byte, error) {
+	properties, err := json.Marshal(geoJSONProperties{
+		Name:    school.Name,
+		Level:   school.Level,
+		Contact: school.Contact,
+		Phone:   school.Phone,
+		Email:   school.Email,
+		Website: school.Website,
+		Address: school.Address,
+		Mode:    school.Mode,
+		Version: school.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// GeoJSON positions are longitude first
+	return json.Marshal(geoJSONFeature{
+		Type: "Feature",
+		ID:   school.ID,
+		Geometry: &geoJSONGeometry{
+			Type:        "Point",
+			Coordinates: []float64{*school.Longitude, *school.Latitude},
+		},
+		Properties: properties,
+	})
+}
+
+// importSchoolsGeoJSONHandler for the "POST" /v1/schools/import/geojson endpoint.
+// Each Point feature becomes a school and is validated like a CSV record
+func (app *application) importSchoolsGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.hasContentType(r, "application/geo+json") && !app.hasContentType(r, "application/json") {
+		app.unsupportedMediaTypeResponse(w, r, "application/geo+json")
+		return
+	}
+	v := validator.New()
+	skipInvalid := app.readBool(r.URL.Query(), "skip_invalid", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var collection geoJSONFeatureCollection
+	err := json.NewDecoder(r.Body).Decode(&collection)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body contains badly-formed GeoJSON: %w", err))
+		return
+	}
+	if collection.Type != "FeatureCollection" {
+		app.badRequestResponse(w, r, errors.New("body must be a GeoJSON FeatureCollection"))
+		return
+	}
+	schools := []*data.School{}
+	rejected := []importRejection{}
+	for i, feature := range collection.Features {
+		school, v := readGeoJSONFeature(feature)
+		if !v.Valid() {
+			rejected = append(rejected, importRejection{Feature: i + 1, Errors: v.Errors})
+			continue
+		}
+		schools = append(schools, school)
+	}
+	app.finishImport(w, r, schools, rejected, skipInvalid)
+}
+
+// The readGeoJSONFeature() function converts a feature into a school and
+// validates it
+func readGeoJSONFeature(feature geoJSONFeature) (*data.School, *validator.Validator) {
+	v := validator.New()
+	v.Check(feature.Type == "Feature", "type", "must be Feature")
+	school := &data.School{}
+	// The geometry must be a single [longitude, latitude] position
+	geometry := feature.Geometry
+	if geometry == nil || geometry.Type != "Point" || len(geometry.Coordinates) < 2 {
+		v.AddError("geometry", "must be a Point")
+	} else {
+		longitude, latitude := geometry.Coordinates[0], geometry.Coordinates[1]
+		school.Longitude = &longitude
+		school.Latitude = &latitude
+	}
+	var properties geoJSONProperties
+	err := json.Unmarshal(feature.Properties, &properties)
+	if err != nil {
+		v.AddError("properties", "must be an object of school fields")
+		return school, v
+	}
+	school.Name = properties.Name
+	school.Level = properties.Level
+	school.Contact = properties.Contact
+	school.Phone = properties.Phone
+	school.Email = properties.Email
+	school.Website = properties.Website
+	school.Address = properties.Address
+	school.Mode = properties.Mode
+	data.ValidateSchool(v, school)
+	return school, v
+}
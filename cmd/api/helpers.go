@@ -9,10 +9,14 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"schools.federicorosado.net/internal/data"
 	"schools.federicorosado.net/internal/validator"
 )
 
@@ -27,6 +31,16 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// The readVersionParam() method reads the ":version" URL parameter
+func (app *application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
+	return int32(version), nil
+}
+
 //Define a new type named envelope
 type envelope map[string]interface {
 }
@@ -150,3 +164,154 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 	return intValue
 }
+
+// the readFloat() method converts a string value from the query string to a float value
+// If the value cannot be converted to a float then a validation error is added to the validation errors map
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	//Get the value
+	value := qs.Get(key)
+	if value == "" {
+		return defaultValue
+	}
+	//perform the conversion to a float
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+	return floatValue
+}
+
+// the readBool() method converts a string value from the query string to a bool value
+// If the value cannot be converted to a bool then a validation error is added to the validation errors map
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	//Get the value
+	value := qs.Get(key)
+	if value == "" {
+		return defaultValue
+	}
+	//perform the conversion to a bool
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return boolValue
+}
+
+// The schoolETag() function derives the entity tag of a school from its
+// version. A sparse fieldset is a different representation of the school, so
+// its sorted field list is part of the tag
+func schoolETag(school *data.School, fields []string) string {
+	if len(fields) == 0 {
+		return fmt.Sprintf(`"%d"`, school.Version)
+	}
+	sorted := append([]string{}, fields...)
+	sort.Strings(sorted)
+	return fmt.Sprintf(`"%d;fields=%s"`, school.Version, strings.Join(sorted, ","))
+}
+
+// Matches the field list part of a sparse fieldset's entity tag
+var etagFieldsRX = regexp.MustCompile(`;fields=[^"]*"`)
+
+// The ifMatchSchool() function reports whether an If-Match header names the
+// current version of the school. Tags from sparse fieldsets name a version
+// as well, so they are accepted along with the tag of the full school
+func ifMatchSchool(header string, school *data.School) bool {
+	return etagMatches(etagFieldsRX.ReplaceAllString(header, `"`), schoolETag(school, nil), false)
+}
+
+// The etagMatches() function reports whether the value of an If-Match or
+// If-None-Match header matches the entity tag. Weak tags (W/"...") are
+// only accepted when weak is true
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range splitETags(header) {
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// The splitETags() function splits an If-Match or If-None-Match header into
+// its entity tags. The tags of sparse fieldsets contain commas, so the header
+// can't just be split on ","
+func splitETags(header string) []string {
+	tags := []string{}
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags
+		}
+		// Anything that isn't a quoted tag, such as "*", runs to the next comma
+		end := strings.IndexByte(header, ',')
+		if end < 0 {
+			end = len(header)
+		}
+		start := 0
+		if strings.HasPrefix(header, "W/") {
+			start = 2
+		}
+		if strings.HasPrefix(header[start:], `"`) {
+			end = len(header)
+			if i := strings.IndexByte(header[start+1:], '"'); i >= 0 {
+				end = start + i + 2
+			}
+		}
+		tags = append(tags, strings.TrimSpace(header[:end]))
+		header = header[end:]
+	}
+}
+
+// nullableFloat tells a JSON field that was left out apart from one that was
+// set to null. Set is true in both cases where the field was sent
+type nullableFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (n *nullableFloat) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Value = nil
+		return nil
+	}
+	return json.Unmarshal(b, &n.Value)
+}
+
+// The extendWriteDeadline() method gives a streamed response longer than the
+// server's WriteTimeout to finish
+func (app *application) extendWriteDeadline(w http.ResponseWriter, timeout time.Duration) error {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+	// Writers such as httptest.ResponseRecorder have no deadline to extend
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// The background() method runs fn in a goroutine that graceful shutdown waits for.
+// Panics in fn are recovered and logged instead of crashing the server.
+func (app *application) background(fn func()) {
+	// Increment the WaitGroup counter
+	app.wg.Add(1)
+	go func() {
+		// Decrement the WaitGroup counter once the task is done
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,86 @@
+// Filename: cmd/api/helpers_test.go
+
+package main
+
+import (
+	"testing"
+
+	"schools.federicorosado.net/internal/data"
+)
+
+func TestSchoolETag(t *testing.T) {
+	school := &data.School{Version: 4}
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{nil, `"4"`},
+		{[]string{"name"}, `"4;fields=name"`},
+		// The order of the fields does not change the representation
+		{[]string{"phone", "id", "name"}, `"4;fields=id,name,phone"`},
+		{[]string{"name", "phone", "id"}, `"4;fields=id,name,phone"`},
+	}
+	for _, tt := range tests {
+		got := schoolETag(school, tt.fields)
+		if got != tt.want {
+			t.Errorf("schoolETag(%v) = %s; want %s", tt.fields, got, tt.want)
+		}
+		// A client sending the tag back in If-None-Match gets a 304
+		for _, header := range []string{got, "W/" + got, `"3", ` + got} {
+			if !etagMatches(header, got, true) {
+				t.Errorf("etagMatches(%s, %s) = false; want true", header, got)
+			}
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{`"4"`, `"4"`, false, true},
+		{`"3", "4"`, `"4"`, false, true},
+		{`"3","4"`, `"4"`, false, true},
+		{`*`, `"4"`, false, true},
+		{`"3"`, `"4"`, false, false},
+		{`W/"4"`, `"4"`, false, false},
+		{`W/"4"`, `"4"`, true, true},
+		{`"4;fields=id,name"`, `"4;fields=id,name"`, false, true},
+		{`"3;fields=id,name", W/"4;fields=id,name"`, `"4;fields=id,name"`, true, true},
+		// Parts of a tag with commas are not tags of their own
+		{`"4;fields=id,name"`, `"4;fields=id"`, false, false},
+		{`"4;fields=id,name"`, `name"`, false, false},
+		{`"4;fields=id,name`, `"4;fields=id,name"`, false, false},
+		{``, `"4"`, false, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, tt.etag, tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%s, %s, %t) = %t; want %t", tt.header, tt.etag, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestIfMatchSchool(t *testing.T) {
+	school := &data.School{Version: 4}
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"4"`, true},
+		{`"4;fields=id,name"`, true},
+		{`"3", "4;fields=name"`, true},
+		{`*`, true},
+		{`"3"`, false},
+		{`"3;fields=name"`, false},
+		// If-Match uses the strong comparison
+		{`W/"4"`, false},
+	}
+	for _, tt := range tests {
+		if got := ifMatchSchool(tt.header, school); got != tt.want {
+			t.Errorf("ifMatchSchool(%s) = %v; want %v", tt.header, got, tt.want)
+		}
+	}
+}
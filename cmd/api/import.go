@@ -0,0 +1,192 @@
+// Filename: cmd/api/import.go
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// Imports can be a lot larger than a normal JSON request body
+const maxImportBytes = 10_485_760
+
+// The columns a school import file may contain
+var importColumns = []string{"name", "level", "contact", "phone", "email", "website", "address", "mode", "latitude", "longitude"}
+
+// Columns written by the CSV export that are allowed but not read, since
+// imported schools always get a new id and start at version 1
+var ignoredImportColumns = []string{"id", "version"}
+
+// importRejection reports why a record of an import was rejected. CSV
+// records are identified by line and GeoJSON records by feature number
+type importRejection struct {
+	Line    int               `json:"line,omitempty"`
+	Feature int               `json:"feature,omitempty"`
+	Errors  map[string]string `json:"errors"`
+}
+
+// importSchoolsHandler for the "POST" /v1/schools/import endpoint.
+// The body is a CSV file with a header row. The mode column holds a
+// semicolon separated list, for example "online;blended"
+func (app *application) importSchoolsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.hasContentType(r, "text/csv") {
+		app.unsupportedMediaTypeResponse(w, r, "text/csv")
+		return
+	}
+	v := validator.New()
+	skipInvalid := app.readBool(r.URL.Query(), "skip_invalid", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	schools, rejected, err := readSchoolsCSV(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	app.finishImport(w, r, schools, rejected, skipInvalid)
+}
+
+// The readSchoolsCSV() function parses and validates every record of a CSV
+// import. Valid schools and rejected records are returned separately
+func readSchoolsCSV(body io.Reader) ([]*data.School, []importRejection, error) {
+	// Excel starts its UTF-8 CSV files with a byte order mark, which would
+	// otherwise end up in the name of the first column
+	buffered := bufio.NewReader(body)
+	if r, _, err := buffered.ReadRune(); err == nil && r != '\ufeff' {
+		buffered.UnreadRune()
+	}
+	reader := csv.NewReader(buffered)
+	reader.TrimLeadingSpace = true
+	// Records with the wrong number of fields are rejected one at a time
+	// below rather than failing the whole file
+	reader.FieldsPerRecord = -1
+	// The header row tells us which column holds which field
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("body must not be empty")
+		}
+		return nil, nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !validator.In(column, importColumns...) && !validator.In(column, ignoredImportColumns...) {
+			return nil, nil, fmt.Errorf("header contains unknown column %q", column)
+		}
+		if _, exists := columns[column]; exists {
+			return nil, nil, fmt.Errorf("header contains duplicate column %q", column)
+		}
+		columns[column] = i
+	}
+	schools := []*data.School{}
+	rejected := []importRejection{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rejected = append(rejected, importRejection{Line: line, Errors: map[string]string{
+				"record": fmt.Sprintf("has %d fields but the header has %d", len(record), len(header)),
+			}})
+			continue
+		}
+		// Look up a field by its column name. Values quoted by the export to
+		// stop spreadsheets running them as formulas are unquoted
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return spreadsheetUnsafe(strings.TrimSpace(record[i]))
+			}
+			return ""
+		}
+		school := &data.School{
+			Name:    field("name"),
+			Level:   field("level"),
+			Contact: field("contact"),
+			Phone:   field("phone"),
+			Email:   field("email"),
+			Website: field("website"),
+			Address: field("address"),
+			Mode:    splitModes(field("mode")),
+		}
+		v := validator.New()
+		school.Latitude = parseCoordinate(v, "latitude", field("latitude"))
+		school.Longitude = parseCoordinate(v, "longitude", field("longitude"))
+		if data.ValidateSchool(v, school); !v.Valid() {
+			rejected = append(rejected, importRejection{Line: line, Errors: v.Errors})
+			continue
+		}
+		schools = append(schools, school)
+	}
+	return schools, rejected, nil
+}
+
+// The splitModes() function splits a semicolon separated list of modes
+func splitModes(value string) []string {
+	modes := []string{}
+	for _, mode := range strings.Split(value, ";") {
+		if mode = strings.TrimSpace(mode); mode != "" {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+// The parseCoordinate() function parses an optional latitude or longitude.
+// An empty value means the school has no location
+func parseCoordinate(v *validator.Validator, key string, value string) *float64 {
+	if value == "" {
+		return nil
+	}
+	coordinate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return nil
+	}
+	return &coordinate
+}
+
+// finishImport() stores the valid schools in one transaction and reports the
+// rejected records. Unless skipInvalid is set, a single rejected record
+// means nothing is stored
+func (app *application) finishImport(w http.ResponseWriter, r *http.Request, schools []*data.School, rejected []importRejection, skipInvalid bool) {
+	if len(rejected) > 0 && !skipInvalid {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, envelope{"rejected": rejected})
+		return
+	}
+	if len(schools) > 0 {
+		err := app.models.Schools.InsertMany(schools, app.contextGetUser(r).ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	env := envelope{"imported": len(schools), "rejected": rejected, "schools": schools}
+	err := app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The hasContentType() method checks the media type of the request body
+func (app *application) hasContentType(r *http.Request, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == contentType
+}
@@ -0,0 +1,104 @@
+// Filename: cmd/api/import_test.go
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSchoolsCSV(t *testing.T) {
+	const header = "name,level,contact,phone,email,website,address,mode\n"
+	const valid = "Belize High,Secondary,Jane Doe,501-223-4567,info@bhs.edu.bz,https://bhs.edu.bz,Hutson Street,in-person;online\n"
+	tests := []struct {
+		name     string
+		body     string
+		schools  []string //names of the valid schools
+		rejected map[int]string
+		err      string
+	}{
+		{
+			name:    "valid",
+			body:    header + valid,
+			schools: []string{"Belize High"},
+		},
+		{
+			name:    "byte order mark",
+			body:    "\ufeff" + header + valid,
+			schools: []string{"Belize High"},
+		},
+		{
+			name:    "byte order mark before a quoted header",
+			body:    "\ufeff\"name\"" + header[len("name"):] + valid,
+			schools: []string{"Belize High"},
+		},
+		{
+			name:     "invalid record",
+			body:     header + valid + "Bad School,Primary,John,not a phone,info@bad.bz,https://bad.bz,Belmopan,online\n",
+			schools:  []string{"Belize High"},
+			rejected: map[int]string{3: "phone"},
+		},
+		{
+			name:     "short record",
+			body:     header + "Short School,Primary\n" + valid,
+			schools:  []string{"Belize High"},
+			rejected: map[int]string{2: "record"},
+		},
+		{
+			name:     "long record",
+			body:     header + valid + strings.TrimSuffix(valid, "\n") + ",extra\n",
+			schools:  []string{"Belize High"},
+			rejected: map[int]string{3: "record"},
+		},
+		{
+			name: "unknown column",
+			body: "name,colour\nBelize High,blue\n",
+			err:  `header contains unknown column "colour"`,
+		},
+		{
+			name: "duplicate column",
+			body: "name,Name\n",
+			err:  `header contains duplicate column "name"`,
+		},
+		{
+			name: "empty body",
+			body: "",
+			err:  "body must not be empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schools, rejected, err := readSchoolsCSV(strings.NewReader(tt.body))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v; want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			names := []string{}
+			for _, school := range schools {
+				names = append(names, school.Name)
+			}
+			if !reflect.DeepEqual(names, tt.schools) {
+				t.Errorf("got schools %v; want %v", names, tt.schools)
+			}
+			if len(rejected) != len(tt.rejected) {
+				t.Fatalf("got rejected %v; want %v", rejected, tt.rejected)
+			}
+			for _, rejection := range rejected {
+				key, ok := tt.rejected[rejection.Line]
+				if !ok {
+					t.Errorf("unexpected rejection of line %d: %v", rejection.Line, rejection.Errors)
+					continue
+				}
+				if _, ok := rejection.Errors[key]; !ok {
+					t.Errorf("line %d errors %v; want a %q error", rejection.Line, rejection.Errors, key)
+				}
+			}
+		})
+	}
+}
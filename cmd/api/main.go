@@ -6,11 +6,15 @@ import (
 	"database/sql"
 	"flag"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
 	"schools.federicorosado.net/internal/data"
 	"schools.federicorosado.net/internal/jsonlog"
+	"schools.federicorosado.net/internal/mailer"
 )
 
 //The application version number
@@ -20,7 +24,15 @@ const version = "1.0.0"
 type config struct {
 	port int
 	env  string //development,   staging, production, etc.
-	db   struct {
+	log  struct {
+		level          jsonlog.Level
+		traceLevel     jsonlog.Level    //lowest level that gets a stack trace
+		traceInterval  time.Duration    //repeated messages are traced once per interval
+		redact         bool             //mask emails, phone numbers and tokens
+		redactKeys     string           //comma separated property keys to mask
+		redactPatterns []*regexp.Regexp //extra patterns to mask
+	}
+	db struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
@@ -31,13 +43,33 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	schools struct {
+		retention    time.Duration //how long soft deleted schools are kept
+		statsRefresh time.Duration //how often the stats view is refreshed
+		exportLimit  int           //how many exports can stream at once, 0 is no limit
+	}
+	metrics struct {
+		port int //0 disables the metrics listener
+	}
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+		dropDir  string //write emails to this directory instead of sending them
+	}
 }
 
 //Dependency Injection
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
+	config      config
+	logger      *jsonlog.Logger
+	models      data.Models
+	mailer      mailer.Mailer
+	instruments *instruments
+	exportSlots chan struct{} //one entry per running export, nil is no limit
+	wg          sync.WaitGroup
 }
 
 func main() {
@@ -50,14 +82,46 @@ func main() {
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conss", 20, "PostgreSQL max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conss", 20, "PostgreSQL max idle connections")
 	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	// Entries below this level are not logged
+	cfg.log.level = jsonlog.LevelInfo
+	flag.Var(&cfg.log.level, "log-level", "Minimum log level (debug | info | warn | error | fatal | off)")
+	// Stack traces are costly, so by default repeated errors only get one a minute
+	cfg.log.traceLevel = jsonlog.LevelError
+	flag.Var(&cfg.log.traceLevel, "log-trace-level", "Minimum level that gets a stack trace (error | fatal | off)")
+	flag.DurationVar(&cfg.log.traceInterval, "log-trace-interval", time.Minute, "Only trace the first of repeated log messages in this interval (0 traces every entry)")
+	// Contact details and tokens must not end up in the logs
+	flag.BoolVar(&cfg.log.redact, "log-redact", true, "Mask emails, phone numbers and tokens in log entries")
+	flag.StringVar(&cfg.log.redactKeys, "log-redact-keys", strings.Join(jsonlog.DefaultRedactKeys, ","), "Comma separated log property keys whose values are masked")
+	flag.Func("log-redact-pattern", "Extra regular expression to mask in log entries (can be repeated)", func(expr string) error {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		cfg.log.redactPatterns = append(cfg.log.redactPatterns, rx)
+		return nil
+	})
 	// These are flags for the rate limiter
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximu requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 2, "Rate limiter maximu burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enabled rate limiter")
+	// Soft deleted schools are purged after this long, 0 keeps them forever
+	flag.DurationVar(&cfg.schools.retention, "schools-retention", 30*24*time.Hour, "How long soft deleted schools are kept before being purged (0 disables purging)")
+	flag.DurationVar(&cfg.schools.statsRefresh, "schools-stats-refresh", time.Hour, "How often the school statistics are refreshed (0 disables refreshing)")
+	// Each export holds a database connection until the client has read it all
+	flag.IntVar(&cfg.schools.exportLimit, "schools-export-limit", 4, "Maximum number of school exports streaming at once (0 is no limit)")
+	// The metrics are served on their own port
+	flag.IntVar(&cfg.metrics.port, "metrics-port", 3001, "Metrics server port (0 disables the metrics server)")
+	// These are flags for the mailer
+	flag.StringVar(&cfg.smtp.host, "smtp-host", os.Getenv("SCH_SMTP_HOST"), "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("SCH_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("SCH_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Schools Directory <no-reply@schools.federicorosado.net>", "SMTP sender")
+	flag.StringVar(&cfg.smtp.dropDir, "smtp-drop-dir", "", "Write emails to this directory instead of sending them over SMTP")
 
 	flag.Parse()
 	//create a logger
-	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+	logger := newLogger(cfg)
 	//Create the connection pool
 	db, err := openDB(cfg)
 	if err != nil {
@@ -66,11 +130,21 @@ func main() {
 	defer db.Close()
 	//Log the successful connection pool
 	logger.PrintInfo("database connection pool established", nil)
+	//Pick the mail transport
+	transport, err := openMailTransport(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
 	//create an instance of our application struct
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:      cfg,
+		logger:      logger,
+		models:      data.NewModels(db),
+		mailer:      mailer.New(transport, cfg.smtp.sender),
+		instruments: newInstruments(db),
+	}
+	if cfg.schools.exportLimit > 0 {
+		app.exportSlots = make(chan struct{}, cfg.schools.exportLimit)
 	}
 
 	//Call app.serve() to start server
@@ -80,6 +154,23 @@ func main() {
 	}
 }
 
+// newLogger() creates the logger with the trace policy and redaction from
+// the config
+func newLogger(cfg config) *jsonlog.Logger {
+	logger := jsonlog.New(os.Stdout, cfg.log.level)
+	if cfg.log.traceInterval > 0 {
+		logger.SetTracePolicy(jsonlog.TraceOncePer(cfg.log.traceLevel, cfg.log.traceInterval))
+	} else {
+		logger.SetTracePolicy(jsonlog.TraceAtLevel(cfg.log.traceLevel))
+	}
+	if cfg.log.redact {
+		patterns := append([]*regexp.Regexp{}, jsonlog.DefaultRedactPatterns...)
+		patterns = append(patterns, cfg.log.redactPatterns...)
+		logger.SetRedactor(jsonlog.NewRedactor(strings.Split(cfg.log.redactKeys, ","), patterns))
+	}
+	return logger
+}
+
 //openDB() function returns a *sql.DB connection pool
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
@@ -103,3 +194,12 @@ func openDB(cfg config) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// openMailTransport() returns the file-drop transport when a drop directory
+// is configured and the SMTP transport otherwise
+func openMailTransport(cfg config) (mailer.Transport, error) {
+	if cfg.smtp.dropDir != "" {
+		return mailer.NewDirTransport(cfg.smtp.dropDir)
+	}
+	return mailer.NewSMTPTransport(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password), nil
+}
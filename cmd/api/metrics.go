@@ -0,0 +1,184 @@
+// Filename: cmd/api/metrics.go
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"schools.federicorosado.net/internal/metrics"
+)
+
+// Requests that never reach a handler, such as 404s from the router and
+// rate limited requests, are counted under this route
+const unmatchedRoute = "unmatched"
+
+// The instruments type holds the metrics recorded by the middleware
+type instruments struct {
+	registry    *metrics.Registry
+	requests    *metrics.CounterVec
+	duration    *metrics.HistogramVec
+	inFlight    *metrics.Gauge
+	rateLimited *metrics.CounterVec
+}
+
+// The newInstruments() function registers the request metrics along with
+// gauges for the database connection pool
+func newInstruments(db *sql.DB) *instruments {
+	registry := metrics.New()
+	ins := &instruments{
+		registry: registry,
+		requests: registry.NewCounterVec("http_requests_total",
+			"Total HTTP requests by method, route pattern and status code.", "method", "route", "status"),
+		duration: registry.NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latencies by method and route pattern.", metrics.DefaultBuckets, "method", "route"),
+		inFlight: registry.NewGauge("http_requests_in_flight",
+			"HTTP requests currently being served."),
+		rateLimited: registry.NewCounterVec("http_rate_limited_total",
+			"Requests rejected by the rate limiter."),
+	}
+	// The pool statistics are read from the database at scrape time
+	stat := func(fn func(s sql.DBStats) float64) func() float64 {
+		return func() float64 { return fn(db.Stats()) }
+	}
+	registry.NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	registry.NewGaugeFunc("db_open_connections", "Established connections, both in use and idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	registry.NewGaugeFunc("db_in_use_connections", "Connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	registry.NewGaugeFunc("db_idle_connections", "Idle connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	registry.NewCounterFunc("db_wait_count_total", "Connections waited for.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	registry.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for new connections.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	registry.NewCounterFunc("db_max_idle_closed_total", "Connections closed due to the idle connection limit.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	registry.NewCounterFunc("db_max_idle_time_closed_total", "Connections closed due to the idle time limit.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	registry.NewCounterFunc("db_max_lifetime_closed_total", "Connections closed due to the connection lifetime limit.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+	return ins
+}
+
+// The responseRecorder type keeps track of the status code and the number of
+// bytes written for a response
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if !rr.wroteHeader {
+		rr.statusCode = statusCode
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap() lets http.ResponseController reach the underlying writer
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// The metrics() middleware records the count, status and latency of every
+// request by its route pattern
+func (app *application) metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		app.instruments.inFlight.Add(1)
+		defer app.instruments.inFlight.Add(-1)
+
+		r, route := app.contextSetRoute(r)
+		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rr, r)
+
+		if *route == "" {
+			*route = unmatchedRoute
+		}
+		method := methodLabel(r.Method)
+		app.instruments.requests.Inc(method, *route, strconv.Itoa(rr.statusCode))
+		app.instruments.duration.Observe(time.Since(start).Seconds(), method, *route)
+	})
+}
+
+// The methods that get their own label value. Series are never removed, so
+// any other method a client makes up is counted as "OTHER"
+var metricsMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// The methodLabel() function returns the method label value for a request
+func methodLabel(method string) string {
+	for _, m := range metricsMethods {
+		if method == m {
+			return method
+		}
+	}
+	return "OTHER"
+}
+
+// The route() helper records the route pattern a handler was registered
+// under, so requests are not counted by their raw URL
+func (app *application) route(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app.contextSetRoutePattern(r, pattern)
+		next(w, r)
+	}
+}
+
+// metricsHandler for the "GET" /debug/metrics endpoint
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	_, err := app.instruments.registry.WriteTo(w)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// The metricsServer() method returns the server for the metrics listener. It
+// is kept off the API port so it is not exposed with the public endpoints
+func (app *application) metricsServer() *http.Server {
+	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router.HandlerFunc(http.MethodGet, "/debug/metrics", app.metricsHandler)
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.metrics.port),
+		Handler:      app.recoverPanic(router),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// serveMetrics() runs the metrics listener until it is shut down
+func (app *application) serveMetrics(srv *http.Server) {
+	app.logger.PrintInfo("starting metrics server", map[string]interface{}{
+		"addr": srv.Addr,
+	})
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.logger.PrintError(err, map[string]interface{}{
+			"addr": srv.Addr,
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Filename: cmd/api/metrics_test.go
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"schools.federicorosado.net/internal/metrics"
+)
+
+func TestMethodLabel(t *testing.T) {
+	tests := []struct {
+		method, want string
+	}{
+		{"GET", "GET"},
+		{"PATCH", "PATCH"},
+		{"OPTIONS", "OPTIONS"},
+		{"BREW", "OTHER"},
+		{"get", "OTHER"},
+		{"", "OTHER"},
+	}
+	for _, tt := range tests {
+		if got := methodLabel(tt.method); got != tt.want {
+			t.Errorf("methodLabel(%q) = %q; want %q", tt.method, got, tt.want)
+		}
+	}
+}
+
+// Made up methods must not add series to the request metrics
+func TestMetricsUnknownMethod(t *testing.T) {
+	registry := metrics.New()
+	app := &application{instruments: &instruments{
+		registry: registry,
+		requests: registry.NewCounterVec("http_requests_total", "", "method", "route", "status"),
+		duration: registry.NewHistogramVec("http_request_duration_seconds", "", metrics.DefaultBuckets, "method", "route"),
+		inFlight: registry.NewGauge("http_requests_in_flight", ""),
+	}}
+	h := app.metrics(app.route("/v1/schools/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	for _, method := range []string{"BREW", "SPILL", "GET"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/v1/schools/1", nil))
+	}
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`http_requests_total{method="OTHER",route="/v1/schools/:id",status="405"} 2`,
+		`http_requests_total{method="GET",route="/v1/schools/:id",status="405"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "BREW") || strings.Contains(out, "SPILL") {
+		t.Errorf("raw method recorded as a label:\n%s", out)
+	}
+}
@@ -3,15 +3,83 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/validator"
 )
 
+// Incoming request IDs are only trusted when they look like this, so clients
+// cannot push arbitrary text into our logs and response headers
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// The requestID() middleware uses the X-Request-ID header from the client or
+// generates a new ID. The ID is added to the request context and echoed back
+// in the response
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			var err error
+			id, err = generateRequestID()
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = app.contextSetRequestID(r, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The generateRequestID() function returns 16 random bytes as a hex string
+func generateRequestID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// The logRequest() middleware writes an access log entry once the response
+// has been sent
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rr, r)
+
+		route := app.contextGetRoutePattern(r)
+		if route == "" {
+			route = unmatchedRoute
+		}
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+		app.logger.WithContext(r.Context()).PrintInfo("request", map[string]interface{}{
+			"request_method": r.Method,
+			"route":          route,
+			"status":         rr.statusCode,
+			"bytes":          rr.bytes,
+			"duration":       time.Since(start),
+			"remote_ip":      remoteIP,
+		})
+	})
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -73,6 +141,7 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			// Check if request allowed
 			if !clients[ip].limiter.Allow() {
 				mu.Unlock()
+				app.instruments.rateLimited.Inc()
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
@@ -84,3 +153,92 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 }
 
 //
+
+// The authenticate() middleware adds the user from the bearer token to the request context
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response varies based on the Authorization header
+		w.Header().Add("Vary", "Authorization")
+		// Get the value of the Authorization header
+		authorizationHeader := r.Header.Get("Authorization")
+		// No header means the client is anonymous
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The header should look like "Bearer <token>"
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		token := headerParts[1]
+		// Validate the token
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		// Fetch the user associated with the token
+		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		// Add the user to the request context
+		r = app.contextSetUser(r, user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireAuthenticatedUser() middleware rejects anonymous users
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireActivatedUser() middleware rejects users that are not activated
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	// Check for an authenticated user first
+	return app.requireAuthenticatedUser(fn)
+}
+
+// The requirePermission() middleware checks that the user has a specific permission code
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		// Get the permissions for the user
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	// The user must be activated before we check permissions
+	return app.requireActivatedUser(fn)
+}
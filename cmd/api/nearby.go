@@ -0,0 +1,49 @@
+// Filename: cmd/api/nearby.go
+
+package main
+
+import (
+	"net/http"
+
+	"schools.federicorosado.net/internal/validator"
+)
+
+// nearbySchoolsHandler for the "GET" /v1/schools/nearby endpoint
+func (app *application) nearbySchoolsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Latitude  float64
+		Longitude float64
+		RadiusKM  float64
+		PageSize  int
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	// The point to search around is required
+	v.Check(qs.Get("lat") != "", "lat", "must be provided")
+	v.Check(qs.Get("lng") != "", "lng", "must be provided")
+	input.Latitude = app.readFloat(qs, "lat", 0, v)
+	input.Longitude = app.readFloat(qs, "lng", 0, v)
+	input.RadiusKM = app.readFloat(qs, "radius_km", 10, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Check the values
+	v.Check(input.Latitude >= -90 && input.Latitude <= 90, "lat", "must be between -90 and 90")
+	v.Check(input.Longitude >= -180 && input.Longitude <= 180, "lng", "must be between -180 and 180")
+	v.Check(input.RadiusKM > 0, "radius_km", "must be greater than zero")
+	v.Check(input.RadiusKM <= 500, "radius_km", "must be maximum of 500")
+	v.Check(input.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(input.PageSize <= 100, "page_size", "must be maximum of 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Get the schools, nearest first
+	schools, err := app.models.Schools.GetNearby(input.Latitude, input.Longitude, input.RadiusKM, input.PageSize)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"schools": schools}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
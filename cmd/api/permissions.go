@@ -0,0 +1,55 @@
+// Filename: cmd/api/permissions.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// grantPermissionsHandler for the "POST" /v1/users/:id/permissions endpoint
+func (app *application) grantPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Parse the permission codes from the request body
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidatePermissionCodes(v, input.Permissions); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Grant the permissions
+	err = app.models.Permissions.AddForUser(id, input.Permissions...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Send back everything the user can now do
+	permissions, err := app.models.Permissions.GetAllForUser(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Filename: cmd/api/revisions.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"schools.federicorosado.net/internal/data"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// listSchoolRevisionsHandler for the "GET" /v1/schools/:id/revisions endpoint
+func (app *application) listSchoolRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Make sure the school exists
+	_, err = app.models.Schools.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Get the revision history, newest first
+	revisions, err := app.models.SchoolRevisions.GetAll(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showSchoolRevisionHandler for the "GET" /v1/schools/:id/revisions/:version endpoint
+func (app *application) showSchoolRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.fetchRevision(w, r)
+	if !ok {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreSchoolRevisionHandler for the "POST" /v1/schools/:id/revisions/:version/restore endpoint.
+// The school is updated to the old values and gets a new version
+func (app *application) restoreSchoolRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.fetchRevision(w, r)
+	if !ok {
+		return
+	}
+	// Fetch the current record from the database
+	school, err := app.models.Schools.Get(revision.SchoolID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Copy the old values over the current ones but keep the current
+	// version so the update is checked for edit conflicts
+	school.Name = revision.School.Name
+	school.Level = revision.School.Level
+	school.Contact = revision.School.Contact
+	school.Phone = revision.School.Phone
+	school.Email = revision.School.Email
+	school.Website = revision.School.Website
+	school.Address = revision.School.Address
+	school.Mode = revision.School.Mode
+	school.Latitude = revision.School.Latitude
+	school.Longitude = revision.School.Longitude
+
+	// Old revisions may predate the current validation rules, so they are
+	// checked again before being written back
+	v := validator.New()
+	if data.ValidateSchool(v, school); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Schools.Update(school, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"school": school}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// fetchRevision() reads the :id and :version parameters and fetches the matching
+// revision. It sends the error response itself and returns false on failure
+func (app *application) fetchRevision(w http.ResponseWriter, r *http.Request) (*data.SchoolRevision, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	revision, err := app.models.SchoolRevisions.Get(id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return revision, true
+}
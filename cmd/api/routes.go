@@ -4,6 +4,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -13,13 +14,63 @@ func (app *application) routes() http.Handler {
 	router := httprouter.New()
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/schools", app.listSchoolHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/schools", app.createSchoolHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/schools/:id", app.showSchoolHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/schools/:id", app.updateSchoolHandler)
+	// Every route is registered through handle() so its pattern is recorded
+	// for the metrics
+	handle := func(method, pattern string, handler http.HandlerFunc) {
+		router.HandlerFunc(method, pattern, app.route(pattern, handler))
+	}
+	handle(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	handle(http.MethodGet, "/v1/schools", app.listSchoolHandler)
+	handle(http.MethodGet, "/v1/schools.geojson", app.listSchoolsGeoJSONHandler)
+	handle(http.MethodPost, "/v1/schools", app.requirePermission("schools:write", app.createSchoolHandler))
+	handle(http.MethodGet, "/v1/schools/:id", app.staticOrID(map[string]http.HandlerFunc{
+		"nearby":  app.nearbySchoolsHandler,
+		"suggest": app.suggestSchoolsHandler,
+	}, app.showSchoolHandler))
+	handle(http.MethodPatch, "/v1/schools/:id", app.requirePermission("schools:write", app.updateSchoolHandler))
 	// router.HandlerFunc(http.MethodPut, "/v1/schools/:id", app.updateSchoolHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/schools/:id", app.deleteSchoolHandler)
+	handle(http.MethodDelete, "/v1/schools/:id", app.requirePermission("schools:write", app.deleteSchoolHandler))
+	handle(http.MethodPost, "/v1/schools/:id", app.staticOrID(map[string]http.HandlerFunc{
+		"import": app.requirePermission("schools:write", app.importSchoolsHandler),
+	}, nil))
+	handle(http.MethodPost, "/v1/schools/:id/geojson", app.staticOrID(map[string]http.HandlerFunc{
+		"import": app.requirePermission("schools:write", app.importSchoolsGeoJSONHandler),
+	}, app.notFoundResponse))
+	handle(http.MethodPost, "/v1/schools/:id/restore", app.requirePermission("schools:write", app.restoreSchoolHandler))
+	handle(http.MethodGet, "/v1/schools/:id/revisions", app.requirePermission("schools:read", app.listSchoolRevisionsHandler))
+	handle(http.MethodGet, "/v1/schools/:id/revisions/:version", app.requirePermission("schools:read", app.showSchoolRevisionHandler))
+	handle(http.MethodPost, "/v1/schools/:id/revisions/:version/restore", app.requirePermission("schools:write", app.restoreSchoolRevisionHandler))
+	handle(http.MethodGet, "/v1/stats/schools", app.requirePermission("schools:read", app.showSchoolStatsHandler))
+	handle(http.MethodPost, "/v1/stats/schools/refresh", app.requirePermission("schools:admin", app.refreshSchoolStatsHandler))
+	handle(http.MethodPost, "/v1/users", app.registerUserHandler)
+	handle(http.MethodPost, "/v1/users/:id/permissions", app.requirePermission("schools:admin", app.grantPermissionsHandler))
+	handle(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	handle(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	handle(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	handle(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
 
-	return app.recoverPanic(app.rateLimit(router))
+	return app.metrics(app.requestID(app.logRequest(app.recoverPanic(app.rateLimit(app.authenticate(router))))))
+}
+
+// httprouter does not allow a static path segment in the same position as a
+// wildcard, so routes such as /v1/schools/import are registered on the
+// /v1/schools/:id route. The staticOrID() helper sends the request to the
+// handler whose key matches the :id parameter and to byID otherwise.
+// A nil byID means the method is not supported for a school id
+func (app *application) staticOrID(static map[string]http.HandlerFunc, byID http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := static[params.ByName("id")]; ok {
+			// Record the static path rather than the :id pattern
+			pattern := app.contextGetRoutePattern(r)
+			app.contextSetRoutePattern(r, strings.Replace(pattern, ":id", params.ByName("id"), 1))
+			handler(w, r)
+			return
+		}
+		if byID == nil {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		byID(w, r)
+	}
 }
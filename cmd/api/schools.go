@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"schools.federicorosado.net/internal/data"
 	"schools.federicorosado.net/internal/validator"
@@ -13,14 +15,16 @@ import (
 func (app *application) createSchoolHandler(w http.ResponseWriter, r *http.Request) {
 	// Our target decode destination
 	var input struct {
-		Name    string   `json:"name"`
-		Level   string   `json:"level"`
-		Contact string   `json:"contact"`
-		Phone   string   `json:"phone"`
-		Email   string   `json:"email"`
-		Website string   `json:"website"`
-		Address string   `json:"address"`
-		Mode    []string `json:"mode"`
+		Name      string   `json:"name"`
+		Level     string   `json:"level"`
+		Contact   string   `json:"contact"`
+		Phone     string   `json:"phone"`
+		Email     string   `json:"email"`
+		Website   string   `json:"website"`
+		Address   string   `json:"address"`
+		Mode      []string `json:"mode"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
 	}
 	//Initialize a new Json.Decoder instance
 	err := app.readJSON(w, r, &input) //json.NewDecoder(r.Body).Decode(&input)
@@ -30,14 +34,16 @@ func (app *application) createSchoolHandler(w http.ResponseWriter, r *http.Reque
 	}
 	//Copy the values from the input struct to a new schools struct
 	school := &data.School{
-		Name:    input.Name,
-		Level:   input.Level,
-		Contact: input.Contact,
-		Phone:   input.Phone,
-		Email:   input.Email,
-		Website: input.Website,
-		Address: input.Address,
-		Mode:    input.Mode,
+		Name:      input.Name,
+		Level:     input.Level,
+		Contact:   input.Contact,
+		Phone:     input.Phone,
+		Email:     input.Email,
+		Website:   input.Website,
+		Address:   input.Address,
+		Mode:      input.Mode,
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
 	}
 
 	//Initialize a new validator instance
@@ -50,9 +56,10 @@ func (app *application) createSchoolHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	//Create a school
-	err = app.models.Schools.Insert(school)
+	err = app.models.Schools.Insert(school, app.contextGetUser(r).ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	// create a location header for the newly created resource/school
@@ -74,8 +81,16 @@ func (app *application) showSchoolHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Read the optional list of fields to return
+	fields := app.readCSV(r.URL.Query(), "fields", nil)
+	v := validator.New()
+	if data.ValidateFields(v, fields, data.SchoolFieldList); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	//Fetch the specifi school
-	school, err := app.models.Schools.Get(id)
+	school, err := app.models.Schools.GetFields(id, fields)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -100,8 +115,18 @@ func (app *application) showSchoolHandler(w http.ResponseWriter, r *http.Request
 	// 	Version:   1,
 	// }
 
+	// The ETag lets clients revalidate their cached copy cheaply
+	etag := schoolETag(school, fields)
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag, true) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	//Write the data returned by Get()
-	err = app.writeJSON(w, http.StatusOK, envelope{"school": school}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"school": pickSchoolFields(school, fields)}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -127,19 +152,26 @@ func (app *application) updateSchoolHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+	// If the client sent the version it expects, make sure it is still current
+	if match := r.Header.Get("If-Match"); match != "" && !ifMatchSchool(match, school) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
 	// Create an input struct to hold data read in from the client
 	// we update the input struct to use pointers because pointers have a
 	// default value of nill
 	//If the field remains nil then we know that the client did not update it
 	var input struct {
-		Name    *string  `json:"name"`
-		Level   *string  `json:"level"`
-		Contact *string  `json:"contact"`
-		Phone   *string  `json:"phone"`
-		Email   *string  `json:"email"`
-		Website *string  `json:"website"`
-		Address *string  `json:"address"`
-		Mode    []string `json:"mode"`
+		Name      *string       `json:"name"`
+		Level     *string       `json:"level"`
+		Contact   *string       `json:"contact"`
+		Phone     *string       `json:"phone"`
+		Email     *string       `json:"email"`
+		Website   *string       `json:"website"`
+		Address   *string       `json:"address"`
+		Mode      []string      `json:"mode"`
+		Latitude  nullableFloat `json:"latitude"`
+		Longitude nullableFloat `json:"longitude"`
 	}
 
 	//Initialize a new Json.Decoder instance
@@ -173,6 +205,13 @@ func (app *application) updateSchoolHandler(w http.ResponseWriter, r *http.Reque
 	if input.Mode != nil {
 		school.Mode = input.Mode
 	}
+	// An explicit null clears the location
+	if input.Latitude.Set {
+		school.Latitude = input.Latitude.Value
+	}
+	if input.Longitude.Set {
+		school.Longitude = input.Longitude.Value
+	}
 	// Copy/update the fields/values in the school variable using the fields
 	// in the input struct
 	// school.Name = input.Name
@@ -196,7 +235,7 @@ func (app *application) updateSchoolHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	// Pass the update school record to the update method
-	err = app.models.Schools.Update(school)
+	err = app.models.Schools.Update(school, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -207,8 +246,12 @@ func (app *application) updateSchoolHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Send the ETag of the new version
+	headers := make(http.Header)
+	headers.Set("ETag", schoolETag(school, nil))
+
 	//Write the data returned by Get()
-	err = app.writeJSON(w, http.StatusOK, envelope{"school": school}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"school": school}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -222,15 +265,41 @@ func (app *application) deleteSchoolHandler(w http.ResponseWriter, r *http.Reque
 		app.notFoundResponse(w, r)
 		return
 	}
+	// If the client sent the version it expects, make sure it is still current.
+	// The delete itself is conditional on that version, so a change made
+	// between the check and the delete is not lost
+	var version int32
+	if match := r.Header.Get("If-Match"); match != "" {
+		school, err := app.models.Schools.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !ifMatchSchool(match, school) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+		// "*" matches any current version
+		if strings.TrimSpace(match) != "*" {
+			version = school.Version
+		}
+	}
 	// Delete the School from the database. Send a 404 Not Found Status code to the
 	//cliet if there is no matching record
-	err = app.models.Schools.Delete(id)
+	err = app.models.Schools.Delete(id, version, app.contextGetUser(r).ID)
 
 	//Handle erros
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -243,14 +312,49 @@ func (app *application) deleteSchoolHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// restoreSchoolHandler for the "POST" /v1/schools/:id/restore endpoint
+func (app *application) restoreSchoolHandler(w http.ResponseWriter, r *http.Request) {
+	//Get the id for the school that needs restoring
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	// Restore the school. Send a 404 Not Found Status code if there is no
+	// soft deleted school with that id
+	err = app.models.Schools.Restore(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// Fetch the restored school
+	school, err := app.models.Schools.Get(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Send the ETag of the new version
+	headers := make(http.Header)
+	headers.Set("ETag", schoolETag(school, nil))
+	err = app.writeJSON(w, http.StatusOK, envelope{"school": school}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // The listSchoolsHandler() allows the client to see a listing of schools
 // based on a set of criteria
 func (app *application) listSchoolHandler(w http.ResponseWriter, r *http.Request) {
 	// Create an input struct to hold our query parameters
 	var input struct {
-		Name  string
-		Level string
-		Mode  []string
+		data.SchoolSearch
+		Format string
+		Facets []string
 		data.Filters
 	}
 	// Initialize a validator
@@ -260,16 +364,40 @@ func (app *application) listSchoolHandler(w http.ResponseWriter, r *http.Request
 	qs := r.URL.Query()
 
 	//Use the helper method to extract the values
-	input.Name = app.readString(qs, "name", "")
-	input.Level = app.readString(qs, "level", "")
-	input.Mode = app.readCSV(qs, "mode", []string{})
-	// Get the informaton
-	input.Filters.Page = app.readInt(qs, "page", 1, v)
-	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.SchoolSearch = app.readSchoolSearch(qs, v)
+	// The format can come from the Accept header, so caches must key on it
+	w.Header().Add("Vary", "Accept")
+	// Pick the response format
+	input.Format = app.negotiateFormat(r)
+	_, ok := exportFormats[input.Format]
+	v.Check(ok, "format", "must be one of json, csv, ndjson or xlsx")
+	// Get the informaton. Exports stream every row so they are not paged
+	input.Filters.Page = 1
+	input.Filters.PageSize = 20
+	if input.Format == "json" {
+		input.Filters.Page = app.readInt(qs, "page", 1, v)
+		input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+		// Passing a cursor, even an empty one, switches to keyset paging
+		input.Filters.UseCursor = qs.Has("cursor")
+		input.Filters.Cursor = qs.Get("cursor")
+	}
 	// Get the sort information
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	// Specific the allowed sort values
-	input.Filters.SortList = []string{"id", "name", "level", "-id", "-name", "-level"}
+	input.Filters.SortList = schoolSortList
+	// Get the fields to return
+	input.Filters.Fields = app.readCSV(qs, "fields", nil)
+	input.Filters.FieldList = data.SchoolFieldList
+	// The export formats always have every column
+	v.Check(input.Format == "json" || len(input.Filters.Fields) == 0, "fields", "can only be used with the json format")
+	// Get the facets to count
+	input.Facets = app.readCSV(qs, "facets", nil)
+	data.ValidateFacets(v, input.Facets)
+	// Relevance is only meaningful when there is something to match
+	if input.Filters.Sort == "relevance" {
+		v.Check(input.HasText(), "sort", "relevance needs a name, level or q value")
+		v.Check(!input.Filters.UseCursor, "cursor", "can't be used with relevance sort")
+	}
 	// Check for validation errors
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -278,17 +406,132 @@ func (app *application) listSchoolHandler(w http.ResponseWriter, r *http.Request
 	// //Results Dump
 	// fmt.Fprintf(w, "%+v\n", input)
 
+	// Only admins can see soft deleted schools
+	if input.IncludeDeleted && !app.authorizeIncludeDeleted(w, r) {
+		return
+	}
+
+	// Stream the whole listing in the requested export format
+	if input.Format != "json" {
+		app.exportSchools(w, r, input.Format, input.SchoolSearch, input.Filters)
+		return
+	}
+
+	// Get the page that follows the cursor
+	if input.Filters.UseCursor {
+		schools, metadata, err := app.models.Schools.GetAllCursor(input.SchoolSearch, input.Filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env := envelope{"schools": pickSchoolsFields(schools, input.Filters.Fields), "metadata": metadata}
+		if !app.addFacets(w, r, env, input.SchoolSearch, input.Facets) {
+			return
+		}
+		err = app.writeJSON(w, http.StatusOK, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	// Get a listing of all schools
-	schools, metadata, err := app.models.Schools.GetAll(input.Name, input.Level, input.Mode, input.Filters)
+	schools, metadata, err := app.models.Schools.GetAll(input.SchoolSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 	// Send a JSON response conting all response
-	err = app.writeJSON(w, http.StatusOK, envelope{"schools": schools, "metadata": metadata}, nil)
+	env := envelope{"schools": pickSchoolsFields(schools, input.Filters.Fields), "metadata": metadata}
+	if !app.addFacets(w, r, env, input.SchoolSearch, input.Facets) {
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
 }
+
+// The addFacets() method adds the requested facet counts to the envelope.
+// It sends the error response and returns false if they can't be counted
+func (app *application) addFacets(w http.ResponseWriter, r *http.Request, env envelope, search data.SchoolSearch, facets []string) bool {
+	if len(facets) == 0 {
+		return true
+	}
+	counts, err := app.models.Schools.GetFacets(search, facets)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	env["facets"] = counts
+	return true
+}
+
+// The pickSchoolFields() function trims a school down to the requested
+// fields. No fields means the whole school is returned
+func pickSchoolFields(school *data.School, fields []string) interface{} {
+	if len(fields) == 0 {
+		return school
+	}
+	return school.PickFields(fields)
+}
+
+// The pickSchoolsFields() function calls pickSchoolFields() for every school
+func pickSchoolsFields(schools []*data.School, fields []string) interface{} {
+	if len(fields) == 0 {
+		return schools
+	}
+	picked := make([]interface{}, len(schools))
+	for i, school := range schools {
+		picked[i] = school.PickFields(fields)
+	}
+	return picked
+}
+
+// The allowed sort values for school listings
+var schoolSortList = []string{"id", "name", "level", "-id", "-name", "-level", "relevance"}
+
+// The readSchoolSearch() method reads the criteria shared by every school listing
+func (app *application) readSchoolSearch(qs url.Values, v *validator.Validator) data.SchoolSearch {
+	return data.SchoolSearch{
+		Name:           app.readString(qs, "name", ""),
+		FuzzyName:      app.readBool(qs, "fuzzy", false, v),
+		Level:          app.readString(qs, "level", ""),
+		Mode:           app.readCSV(qs, "mode", []string{}),
+		Query:          app.readString(qs, "q", ""),
+		IncludeDeleted: app.readBool(qs, "include_deleted", false, v),
+	}
+}
+
+// The authorizeIncludeDeleted() method checks that the user may see soft
+// deleted schools. It sends the error response and returns false if not
+func (app *application) authorizeIncludeDeleted(w http.ResponseWriter, r *http.Request) bool {
+	return app.authorize(w, r, "schools:admin")
+}
+
+// The authorize() method does the same checks as requirePermission() for
+// handlers where only some requests need the permission. It sends the error
+// response and returns false if the user does not have it
+func (app *application) authorize(w http.ResponseWriter, r *http.Request, code string) bool {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		app.authenticationRequiredResponse(w, r)
+		return false
+	}
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return false
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !permissions.Include(code) {
+		app.notPermittedResponse(w, r)
+		return false
+	}
+	return true
+}
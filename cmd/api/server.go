@@ -6,26 +6,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"schools.federicorosado.net/internal/jsonlog"
 )
 
-func (app *application) serve() error {
-	//create our HTTP server
-	srv := &http.Server{
+// The newServer() method creates the API server
+func (app *application) newServer() *http.Server {
+	return &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
-		ErrorLog:     log.New(app.logger, "", 0),
+		ErrorLog:     app.logger.ErrorLog(jsonlog.LevelWarn), //mostly client trouble such as failed TLS handshakes
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+}
+
+func (app *application) serve() error {
+	//create our HTTP server
+	srv := app.newServer()
+	// The metrics get their own listener when a port is configured
+	var metricsSrv *http.Server
+	if app.config.metrics.port > 0 {
+		metricsSrv = app.metricsServer()
+		go app.serveMetrics(metricsSrv)
+	}
 	// The shutdown() function should return its error to this channel
 	shutdownError := make(chan error)
+	// Closing this channel stops the periodic jobs
+	stopJobs := make(chan struct{})
+	defer close(stopJobs)
+	go app.every(time.Hour, app.config.schools.retention > 0, stopJobs, app.purgeDeletedSchools)
+	go app.every(app.config.schools.statsRefresh, app.config.schools.statsRefresh > 0, stopJobs, app.refreshSchoolStats)
 
 	//start a background Goroutine
 	go func() {
@@ -36,18 +53,45 @@ func (app *application) serve() error {
 		// Block until a signal is recieved
 		s := <-quit
 		// Log a message
-		app.logger.PrintInfo("shutting down server", map[string]string{
+		app.logger.PrintInfo("shutting down server", map[string]interface{}{
 			"signal": s.String(),
 		})
 		// Create a  context with a 20 second timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
 		//Call the Shutdown() function
-		shutdownError <- srv.Shutdown(ctx)
+		if metricsSrv != nil {
+			// The API server is what matters here, so only log this error
+			if err := metricsSrv.Shutdown(ctx); err != nil {
+				app.logger.PrintError(err, map[string]interface{}{
+					"addr": metricsSrv.Addr,
+				})
+			}
+		}
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+		// Wait for the background tasks, but no longer than the context allows
+		app.logger.PrintInfo("completing background tasks", map[string]interface{}{
+			"addr": srv.Addr,
+		})
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			shutdownError <- nil
+		case <-ctx.Done():
+			shutdownError <- ctx.Err()
+		}
 	}()
 
 	//Start our server
-	app.logger.PrintInfo("starting server", map[string]string{
+	app.logger.PrintInfo("starting server", map[string]interface{}{
 		"addr": srv.Addr,
 		"env":  app.config.env,
 	})
@@ -63,8 +107,49 @@ func (app *application) serve() error {
 		return err
 	}
 	//Gracefull shutdown was successful
-	app.logger.PrintInfo("stopped server", map[string]string{
+	app.logger.PrintInfo("stopped server", map[string]interface{}{
 		"addr": srv.Addr,
 	})
 	return nil
 }
+
+// every() calls fn straight away and then once per interval until stop is
+// closed. Nothing is run if enabled is false
+func (app *application) every(interval time.Duration, enabled bool, stop <-chan struct{}, fn func()) {
+	if !enabled {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fn()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeDeletedSchools() permanently removes soft deleted schools once they
+// are older than the retention window
+func (app *application) purgeDeletedSchools() {
+	purged, err := app.models.Schools.PurgeDeleted(time.Now().Add(-app.config.schools.retention))
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+	if purged > 0 {
+		app.logger.PrintInfo("purged deleted schools", map[string]interface{}{
+			"count": purged,
+		})
+	}
+}
+
+// refreshSchoolStats() recomputes the school statistics view
+func (app *application) refreshSchoolStats() {
+	err := app.models.SchoolStats.Refresh()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
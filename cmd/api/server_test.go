@@ -0,0 +1,33 @@
+// Filename: cmd/api/server_test.go
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"schools.federicorosado.net/internal/jsonlog"
+)
+
+// The server's own errors are mostly client trouble, so they are logged as
+// warnings rather than errors
+func TestServerErrorLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	app := &application{logger: jsonlog.New(&buf, jsonlog.LevelInfo)}
+	srv := app.newServer()
+	srv.ErrorLog.Printf("http: TLS handshake error from %s: EOF", "10.0.0.1:4000")
+	var entry struct {
+		Level   string `json:"level"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if entry.Level != "WARN" {
+		t.Errorf("got level %s; want WARN", entry.Level)
+	}
+	if entry.Message != "http: TLS handshake error from 10.0.0.1:4000: EOF" {
+		t.Errorf("got message %q", entry.Message)
+	}
+}
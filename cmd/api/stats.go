@@ -0,0 +1,31 @@
+// Filename: cmd/api/stats.go
+
+package main
+
+import (
+	"net/http"
+)
+
+// showSchoolStatsHandler for the "GET" /v1/stats/schools endpoint
+func (app *application) showSchoolStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.models.SchoolStats.Get()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// refreshSchoolStatsHandler for the "POST" /v1/stats/schools/refresh endpoint
+func (app *application) refreshSchoolStatsHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.SchoolStats.Refresh()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Send back the fresh numbers
+	app.showSchoolStatsHandler(w, r)
+}
@@ -0,0 +1,40 @@
+// Filename: cmd/api/suggest.go
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"schools.federicorosado.net/internal/validator"
+)
+
+// suggestSchoolsHandler for the "GET" /v1/schools/suggest endpoint
+func (app *application) suggestSchoolsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		Limit int
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Query = strings.TrimSpace(app.readString(qs, "q", ""))
+	input.Limit = app.readInt(qs, "limit", 10, v)
+	// Check the values
+	v.Check(input.Query != "", "q", "must be provided")
+	v.Check(len(input.Query) <= 200, "q", "must not be more than 200 bytes long")
+	v.Check(input.Limit > 0, "limit", "must be greater than zero")
+	v.Check(input.Limit <= 50, "limit", "must be maximum of 50")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	suggestions, err := app.models.Schools.Suggest(input.Query, input.Limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
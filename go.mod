@@ -1,6 +1,6 @@
 module schools.federicorosado.net
 
-go 1.18
+go 1.21
 
 require (
 	github.com/julienschmidt/httprouter v1.3.0
@@ -9,4 +9,4 @@ require (
 
 require golang.org/x/time v0.1.0
 
-require golang.org/x/crypto v0.1.0 // indirect
+require golang.org/x/crypto v0.1.0
@@ -0,0 +1,84 @@
+// Filename: internal/data/facets.go
+
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"schools.federicorosado.net/internal/validator"
+)
+
+// SchoolFacetList holds the fields that can be counted with ?facets=
+var SchoolFacetList = []string{"level", "mode"}
+
+// Facets maps each facet to the number of schools for each of its values
+type Facets map[string]map[string]int
+
+// ValidateFacets() checks that every requested facet is in the acceptable facet list
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		v.Check(validator.In(facet, SchoolFacetList...), "facets", "invalid facet value "+facet)
+	}
+}
+
+// GetFacets() counts the schools that match the search for every value of
+// the requested facets. A school is counted once for each of its modes
+func (m SchoolModel) GetFacets(search SchoolSearch, facets []string) (Facets, error) {
+	where, args := search.where()
+	result := make(Facets)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, facet := range facets {
+		var query string
+		switch facet {
+		case "level":
+			query = fmt.Sprintf(`
+				SELECT level, COUNT(*)
+				FROM schools
+				%s
+				GROUP BY level`, where)
+		case "mode":
+			query = fmt.Sprintf(`
+				SELECT m, COUNT(*)
+				FROM schools, unnest(mode) AS m
+				%s
+				GROUP BY m`, where)
+		default:
+			panic("unsafe facet parameter: " + facet)
+		}
+		counts, err := m.countFacet(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		result[facet] = counts
+	}
+	return result, nil
+}
+
+// countFacet() runs a facet query that returns value and count pairs
+func (m SchoolModel) countFacet(ctx context.Context, query string, args []interface{}) (map[string]int, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var value string
+		var count int
+		err := rows.Scan(&value, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[value] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
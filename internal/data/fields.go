@@ -0,0 +1,111 @@
+// Filename: internal/data/fields.go
+
+package data
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// SchoolFieldList holds the school fields a client can ask for with ?fields=
+var SchoolFieldList = []string{"id", "name", "level", "contact", "phone", "email", "website", "address", "mode", "latitude", "longitude", "version"}
+
+// The schoolColumns() function returns the select list for the requested
+// fields along with a function that gives the matching scan destinations.
+// The fields must come from SchoolFieldList. No fields means every column
+func schoolColumns(fields []string, required ...string) (string, func(*School) []interface{}) {
+	if len(fields) == 0 {
+		fields = append([]string{"created_at", "deleted_at"}, SchoolFieldList...)
+	} else {
+		// Always select the fields the query needs for itself
+		fields = append([]string{}, fields...)
+		for _, field := range required {
+			if !validator.In(field, fields...) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	dest := func(school *School) []interface{} {
+		targets := make([]interface{}, len(fields))
+		for i, field := range fields {
+			targets[i] = school.fieldTarget(field)
+		}
+		return targets
+	}
+	return strings.Join(fields, ", "), dest
+}
+
+// The fieldTarget() method returns the scan destination of a column
+func (school *School) fieldTarget(field string) interface{} {
+	switch field {
+	case "id":
+		return &school.ID
+	case "created_at":
+		return &school.CreatedAt
+	case "name":
+		return &school.Name
+	case "level":
+		return &school.Level
+	case "contact":
+		return &school.Contact
+	case "phone":
+		return &school.Phone
+	case "email":
+		return &school.Email
+	case "website":
+		return &school.Website
+	case "address":
+		return &school.Address
+	case "mode":
+		return pq.Array(&school.Mode)
+	case "latitude":
+		return &school.Latitude
+	case "longitude":
+		return &school.Longitude
+	case "version":
+		return &school.Version
+	case "deleted_at":
+		return &school.DeletedAt
+	}
+	panic("unknown school field: " + field)
+}
+
+// PickFields() returns only the requested fields of the school, keyed by
+// their JSON names, so the response leaves out everything else
+func (school *School) PickFields(fields []string) map[string]interface{} {
+	picked := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "id":
+			picked[field] = school.ID
+		case "name":
+			picked[field] = school.Name
+		case "level":
+			picked[field] = school.Level
+		case "contact":
+			picked[field] = school.Contact
+		case "phone":
+			picked[field] = school.Phone
+		case "email":
+			picked[field] = school.Email
+		case "website":
+			picked[field] = school.Website
+		case "address":
+			picked[field] = school.Address
+		case "mode":
+			picked[field] = school.Mode
+		case "latitude":
+			picked[field] = school.Latitude
+		case "longitude":
+			picked[field] = school.Longitude
+		case "version":
+			picked[field] = school.Version
+		}
+	}
+	if school.Highlights != nil {
+		picked["highlights"] = school.Highlights
+	}
+	return picked
+}
@@ -3,7 +3,11 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"schools.federicorosado.net/internal/validator"
@@ -14,6 +18,13 @@ type Filters struct {
 	PageSize int
 	Sort     string
 	SortList []string
+	// In cursor mode rows are paged by keyset instead of by page number.
+	// An empty Cursor starts from the first row
+	UseCursor bool
+	Cursor    string
+	// Only the fields in Fields are selected. An empty list means all of them
+	Fields    []string
+	FieldList []string
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -24,6 +35,23 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be maximum of 100")
 	// Check that the sort parameter matches a value in the acceptable sort list
 	v.Check(validator.In(f.Sort, f.SortList...), "sort", "invalid sort value")
+	// Check that every requested field is in the acceptable field list
+	ValidateFields(v, f.Fields, f.FieldList)
+	// A cursor only makes sense with the sort it was created for
+	if f.UseCursor && f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "invalid cursor value")
+		if err == nil {
+			v.Check(c.Sort == f.Sort, "cursor", "does not match the sort value")
+		}
+	}
+}
+
+// ValidateFields() checks that every requested field is in the acceptable field list
+func ValidateFields(v *validator.Validator, fields []string, fieldList []string) {
+	for _, field := range fields {
+		v.Check(validator.In(field, fieldList...), "fields", "invalid field value "+field)
+	}
 }
 
 // The sortColmn() method safety extracts the sort field query parameter
@@ -54,13 +82,82 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// A cursor marks the last row of a keyset page. It holds the value of the
+// sort column (as text) and the id, which breaks ties between equal values
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"i"`
+}
+
+// The encode() method turns the cursor into an opaque string
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// The decodeCursor() function reverses cursor.encode()
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(js, &c)
+	return c, err
+}
+
+// The keyset() method returns the condition that selects the rows after the
+// cursor, with placeholders starting at $n. The rows are ordered by the sort
+// column and then by id ASC, so rows with an equal sort value continue by id
+func (f Filters) keyset(n int) (string, []interface{}, error) {
+	if f.Cursor == "" {
+		return "TRUE", nil, nil
+	}
+	c, err := decodeCursor(f.Cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	column := f.sortColumn()
+	operator := ">"
+	if f.sortOrder() == "DESC" {
+		operator = "<"
+	}
+	if column == "id" {
+		return fmt.Sprintf("id %s $%d", operator, n), []interface{}{c.ID}, nil
+	}
+	clause := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id > $%[4]d))", column, operator, n, n+1)
+	return clause, []interface{}{c.Value, c.ID}, nil
+}
+
+// The nextCursor() method creates the cursor that continues after the school
+func (f Filters) nextCursor(school *School) string {
+	c := cursor{Sort: f.Sort, ID: school.ID}
+	switch f.sortColumn() {
+	case "name":
+		c.Value = school.Name
+	case "level":
+		c.Value = school.Level
+	default:
+		c.Value = strconv.FormatInt(school.ID, 10)
+	}
+	return c.encode()
+}
+
+// CursorMetadata is returned instead of Metadata in cursor mode. An empty
+// NextCursor means there are no more rows
+type CursorMetadata struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 // The Metadata type contains metadata to help with pagination
 type Metadata struct {
-	CurrentPage  int `json: "current_page, omitempty"`
-	PageSize     int `json: "page_size, omitempty"`
-	FirstPage    int `json: "first_page, omitempty"`
-	LastPage     int `json: "last_page, omitempty"`
-	TotalRecords int `json: "total_records, omitempty"`
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
 }
 
 // The calculateMetadata() functions computes the values For the Metada fields
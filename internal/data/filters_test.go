@@ -0,0 +1,111 @@
+// Filename: internal/data/filters_test.go
+
+package data
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"schools.federicorosado.net/internal/validator"
+)
+
+var testSortList = []string{"id", "name", "level", "-id", "-name", "-level"}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		sort   string
+		cursor string
+		clause string
+		args   []interface{}
+	}{
+		// No cursor starts from the first row
+		{"name", "", "TRUE", nil},
+		{"id", cursor{Sort: "id", Value: "7", ID: 7}.encode(), "id > $3", []interface{}{int64(7)}},
+		{"-id", cursor{Sort: "-id", Value: "7", ID: 7}.encode(), "id < $3", []interface{}{int64(7)}},
+		// Rows with the same sort value continue by id
+		{"name", cursor{Sort: "name", Value: "Belize High", ID: 12}.encode(),
+			"(name > $3 OR (name = $3 AND id > $4))", []interface{}{"Belize High", int64(12)}},
+		{"-level", cursor{Sort: "-level", Value: "Primary", ID: 4}.encode(),
+			"(level < $3 OR (level = $3 AND id > $4))", []interface{}{"Primary", int64(4)}},
+	}
+	for _, tt := range tests {
+		f := Filters{Sort: tt.sort, SortList: testSortList, UseCursor: true, Cursor: tt.cursor}
+		clause, args, err := f.keyset(3)
+		if err != nil {
+			t.Errorf("keyset() for sort %q: %v", tt.sort, err)
+			continue
+		}
+		if clause != tt.clause {
+			t.Errorf("keyset() clause for sort %q = %q; want %q", tt.sort, clause, tt.clause)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("keyset() args for sort %q = %v; want %v", tt.sort, args, tt.args)
+		}
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	school := &School{ID: 12, Name: "Belize High", Level: "Secondary"}
+	tests := []struct {
+		sort string
+		want cursor
+	}{
+		{"id", cursor{Sort: "id", Value: "12", ID: 12}},
+		{"-name", cursor{Sort: "-name", Value: "Belize High", ID: 12}},
+		{"level", cursor{Sort: "level", Value: "Secondary", ID: 12}},
+	}
+	for _, tt := range tests {
+		f := Filters{Sort: tt.sort, SortList: testSortList}
+		got, err := decodeCursor(f.nextCursor(school))
+		if err != nil {
+			t.Fatalf("decodeCursor(): %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("nextCursor() for sort %q = %+v; want %+v", tt.sort, got, tt.want)
+		}
+		// The cursor continues with the same sort
+		f.UseCursor, f.Cursor = true, f.nextCursor(school)
+		f.Page, f.PageSize = 1, 20
+		v := validator.New()
+		if ValidateFilters(v, f); !v.Valid() {
+			t.Errorf("cursor for sort %q is not valid: %v", tt.sort, v.Errors)
+		}
+	}
+}
+
+func TestValidateFiltersCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		valid  bool
+	}{
+		{"first page", "name", "", true},
+		{"same sort", "name", cursor{Sort: "name", Value: "A", ID: 1}.encode(), true},
+		{"different sort", "-name", cursor{Sort: "name", Value: "A", ID: 1}.encode(), false},
+		{"bad base64", "name", "not*base64!", false},
+		{"bad json", "name", base64.RawURLEncoding.EncodeToString([]byte(`{"s":`)), false},
+		{"json of the wrong type", "name", base64.RawURLEncoding.EncodeToString([]byte(`{"i":"one"}`)), false},
+	}
+	for _, tt := range tests {
+		f := Filters{Page: 1, PageSize: 20, Sort: tt.sort, SortList: testSortList, UseCursor: true, Cursor: tt.cursor}
+		v := validator.New()
+		ValidateFilters(v, f)
+		if v.Valid() != tt.valid {
+			t.Errorf("%s: valid = %t; want %t (errors %v)", tt.name, v.Valid(), tt.valid, v.Errors)
+		}
+		if !tt.valid && v.Errors["cursor"] == "" {
+			t.Errorf("%s: want a cursor error, got %v", tt.name, v.Errors)
+		}
+	}
+}
+
+func TestKeysetMalformedCursor(t *testing.T) {
+	for _, c := range []string{"not*base64!", base64.RawURLEncoding.EncodeToString([]byte("[1,2]"))} {
+		f := Filters{Sort: "name", SortList: testSortList, UseCursor: true, Cursor: c}
+		if _, _, err := f.keyset(1); err == nil {
+			t.Errorf("keyset() with cursor %q: want an error", c)
+		}
+	}
+}
@@ -14,14 +14,22 @@ var (
 
 // A wrapper for our data models
 type Models struct {
-	Schools SchoolModel
-	Users   UserModel
+	Permissions     PermissionModel
+	Schools         SchoolModel
+	SchoolRevisions SchoolRevisionModel
+	SchoolStats     SchoolStatsModel
+	Tokens          TokenModel
+	Users           UserModel
 }
 
 // NewModels() allow us to create a new models
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Schools: SchoolModel{DB: db},
-		Users:   UserModel{DB: db},
+		Permissions:     PermissionModel{DB: db},
+		Schools:         SchoolModel{DB: db},
+		SchoolRevisions: SchoolRevisionModel{DB: db},
+		SchoolStats:     SchoolStatsModel{DB: db},
+		Tokens:          TokenModel{DB: db},
+		Users:           UserModel{DB: db},
 	}
 }
@@ -0,0 +1,111 @@
+// Filename: internal/data/nearby.go
+
+package data
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The mean radius of the earth in kilometres
+const earthRadiusKM = 6371.0
+
+// NearbySchool is a school along with its distance from the search point
+type NearbySchool struct {
+	*School
+	DistanceKM float64 `json:"distance_km"`
+}
+
+// The boundingBox() function returns the latitude and longitude ranges that
+// contain every point within radiusKM of the centre. The box is a cheap
+// prefilter that lets the location index do most of the work
+func boundingBox(lat, lng, radiusKM float64) (minLat, maxLat, minLng, maxLng float64) {
+	// One degree of latitude is always the same distance
+	dLat := radiusKM / earthRadiusKM * 180 / math.Pi
+	minLat = math.Max(lat-dLat, -90)
+	maxLat = math.Min(lat+dLat, 90)
+	// Near the poles, or when the box crosses the antimeridian, every
+	// longitude has to be searched
+	if minLat == -90 || maxLat == 90 {
+		return minLat, maxLat, -180, 180
+	}
+	// The widest point of the circle is not level with the centre, so the
+	// longitude half-width comes from spherical trigonometry rather than
+	// scaling dLat by the latitude
+	ratio := math.Sin(radiusKM/earthRadiusKM) / math.Cos(lat*math.Pi/180)
+	if ratio >= 1 {
+		return minLat, maxLat, -180, 180
+	}
+	dLng := math.Asin(ratio) * 180 / math.Pi
+	minLng = lng - dLng
+	maxLng = lng + dLng
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180
+	}
+	return minLat, maxLat, minLng, maxLng
+}
+
+// GetNearby() returns up to limit schools within radiusKM of the point,
+// nearest first. The great-circle distance is computed with the haversine formula
+func (m SchoolModel) GetNearby(lat, lng, radiusKM float64, limit int) ([]*NearbySchool, error) {
+	query := `
+		SELECT id, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude, version, distance
+		FROM (
+			SELECT *, 2 * $1::float8 * asin(sqrt(
+				power(sin(radians(latitude - $2) / 2), 2) +
+				cos(radians($2)) * cos(radians(latitude)) * power(sin(radians(longitude - $3) / 2), 2)
+			)) AS distance
+			FROM schools
+			WHERE deleted_at IS NULL
+			AND latitude BETWEEN $4 AND $5
+			AND longitude BETWEEN $6 AND $7
+		) AS candidates
+		WHERE distance <= $8
+		ORDER BY distance ASC, id ASC
+		LIMIT $9
+	`
+	minLat, maxLat, minLng, maxLng := boundingBox(lat, lng, radiusKM)
+	args := []interface{}{earthRadiusKM, lat, lng, minLat, maxLat, minLng, maxLng, radiusKM, limit}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schools := []*NearbySchool{}
+	for rows.Next() {
+		var school School
+		var distance float64
+		err := rows.Scan(
+			&school.ID,
+			&school.CreatedAt,
+			&school.Name,
+			&school.Level,
+			&school.Contact,
+			&school.Phone,
+			&school.Email,
+			&school.Website,
+			&school.Address,
+			pq.Array(&school.Mode),
+			&school.Latitude,
+			&school.Longitude,
+			&school.Version,
+			&distance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		schools = append(schools, &NearbySchool{School: &school, DistanceKM: distance})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return schools, nil
+}
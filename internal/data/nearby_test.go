@@ -0,0 +1,81 @@
+// Filename: internal/data/nearby_test.go
+
+package data
+
+import (
+	"math"
+	"testing"
+)
+
+// The destination() function returns the point distanceKM away from the
+// start along the bearing, in degrees
+func destination(lat, lng, bearing, distanceKM float64) (float64, float64) {
+	rad := math.Pi / 180
+	d := distanceKM / earthRadiusKM
+	lat1, lng1, theta := lat*rad, lng*rad, bearing*rad
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(theta))
+	lng2 := lng1 + math.Atan2(math.Sin(theta)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	// Wrap the longitude back into [-180, 180]
+	lng2 = math.Remainder(lng2, 2*math.Pi)
+	return lat2 / rad, lng2 / rad
+}
+
+func TestBoundingBoxContainsCircle(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, lng, rad float64
+	}{
+		{"Belize City 10km", 17.5, -88.2, 10},
+		{"equator 500km", 0, 0, 500},
+		{"southern 2000km", -35, 150, 2000},
+		{"high latitude 1500km", 70, 20, 1500},
+		{"high latitude 3000km", 65, -40, 3000},
+		{"far south 800km", -75, 100, 800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minLat, maxLat, minLng, maxLng := boundingBox(tt.lat, tt.lng, tt.rad)
+			// Walk around the edge of the circle, just inside the radius
+			for bearing := 0.0; bearing < 360; bearing += 0.5 {
+				lat, lng := destination(tt.lat, tt.lng, bearing, tt.rad*0.9999)
+				if lat < minLat || lat > maxLat || lng < minLng || lng > maxLng {
+					t.Fatalf("point (%.4f, %.4f) at bearing %.1f is outside the box lat [%.4f, %.4f] lng [%.4f, %.4f]",
+						lat, lng, bearing, minLat, maxLat, minLng, maxLng)
+				}
+			}
+		})
+	}
+}
+
+func TestBoundingBoxIsTight(t *testing.T) {
+	// The box edges should touch the circle, not just contain it
+	lat, lng, radius := 70.0, 20.0, 1500.0
+	_, _, minLng, maxLng := boundingBox(lat, lng, radius)
+	widest := 0.0
+	for bearing := 0.0; bearing <= 180; bearing += 0.01 {
+		_, p := destination(lat, lng, bearing, radius)
+		widest = math.Max(widest, p-lng)
+	}
+	if math.Abs((maxLng-lng)-widest) > 0.01 || math.Abs((lng-minLng)-widest) > 0.01 {
+		t.Errorf("got half-width %.4f; want %.4f", maxLng-lng, widest)
+	}
+}
+
+func TestBoundingBoxWholeLongitudeRange(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, lng, rad float64
+	}{
+		{"covers the north pole", 85, 0, 1000},
+		{"covers the south pole", -88, 45, 500},
+		{"crosses the antimeridian", 10, 179, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, minLng, maxLng := boundingBox(tt.lat, tt.lng, tt.rad)
+			if minLng != -180 || maxLng != 180 {
+				t.Errorf("got lng [%.4f, %.4f]; want [-180, 180]", minLng, maxLng)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Filename: internal/data/permissions.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"schools.federicorosado.net/internal/validator"
+)
+
+// PermissionCodes are all the permission codes that can be granted
+var PermissionCodes = []string{"schools:read", "schools:write", "schools:admin"}
+
+// ValidatePermissionCodes() checks a list of codes to grant
+func ValidatePermissionCodes(v *validator.Validator, codes []string) {
+	v.Check(len(codes) != 0, "permissions", "must contain at least 1 entry")
+	v.Check(validator.Unique(codes), "permissions", "must not contain duplicate entries")
+	for _, code := range codes {
+		v.Check(validator.In(code, PermissionCodes...), "permissions", "contains an unknown permission code")
+	}
+}
+
+// Permissions holds the permission codes for a single user
+type Permissions []string
+
+// Include() checks if the slice contains a specific permission code
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Define the permission model
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser() returns all the permission codes for a specific user
+func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		INNER JOIN users ON users_permissions.user_id = users.id
+		WHERE users.id = $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// AddForUser() grants the provided permission codes to a specific user.
+// Codes the user already has are left alone
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return addPermissions(ctx, m.DB, userID, codes)
+}
+
+// addPermissions() grants the permission codes to a user using db
+func addPermissions(ctx context.Context, db execQueryer, userID int64, codes []string) error {
+	query := `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := db.ExecContext(ctx, query, userID, pq.Array(codes))
+	if err != nil {
+		// The user does not exist
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Filename: internal/data/revisions.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A SchoolRevision is a snapshot of a school as it was at a specific version
+type SchoolRevision struct {
+	SchoolID  int64     `json:"school_id"`
+	Version   int32     `json:"version"`
+	UserID    *int64    `json:"user_id"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+	School    *School   `json:"school"`
+}
+
+// The changes a revision can record
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// execQueryer is satisfied by both *sql.DB and *sql.Tx
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertRevision() records the current state of the school and the action
+// that produced it. A userID of 0 means the change was not made by a known user
+func insertRevision(ctx context.Context, db execQueryer, school *School, userID int64, action string) error {
+	query := `
+		INSERT INTO school_revisions (school_id, version, user_id, action, name, level, contact, phone, email, website, address, mode, latitude, longitude)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`
+	args := []interface{}{
+		school.ID, school.Version, userID, action,
+		school.Name, school.Level,
+		school.Contact, school.Phone,
+		school.Email, school.Website,
+		school.Address, pq.Array(school.Mode),
+		school.Latitude, school.Longitude,
+	}
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Define the school revision model
+type SchoolRevisionModel struct {
+	DB *sql.DB
+}
+
+// GetAll() returns every revision of a school, newest first
+func (m SchoolRevisionModel) GetAll(schoolID int64) ([]*SchoolRevision, error) {
+	query := `
+		SELECT school_id, version, user_id, action, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude
+		FROM school_revisions
+		WHERE school_id = $1
+		ORDER BY version DESC
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, schoolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*SchoolRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Get() returns a specific revision of a school
+func (m SchoolRevisionModel) Get(schoolID int64, version int32) (*SchoolRevision, error) {
+	if schoolID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT school_id, version, user_id, action, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude
+		FROM school_revisions
+		WHERE school_id = $1
+		AND version = $2
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	revision, err := scanRevision(m.DB.QueryRowContext(ctx, query, schoolID, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return revision, nil
+}
+
+// scanRevision() scans a single school_revisions row
+func scanRevision(row interface{ Scan(...interface{}) error }) (*SchoolRevision, error) {
+	var revision SchoolRevision
+	var school School
+	err := row.Scan(
+		&revision.SchoolID,
+		&revision.Version,
+		&revision.UserID,
+		&revision.Action,
+		&revision.CreatedAt,
+		&school.Name,
+		&school.Level,
+		&school.Contact,
+		&school.Phone,
+		&school.Email,
+		&school.Website,
+		&school.Address,
+		pq.Array(&school.Mode),
+		&school.Latitude,
+		&school.Longitude,
+	)
+	if err != nil {
+		return nil, err
+	}
+	school.ID = revision.SchoolID
+	school.Version = revision.Version
+	revision.School = &school
+	return &revision, nil
+}
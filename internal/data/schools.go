@@ -14,17 +14,22 @@ import (
 )
 
 type School struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Name      string    `json:"name"`
-	Level     string    `json:"level"`
-	Contact   string    `json:"contact"`
-	Phone     string    `json:"phone"`
-	Email     string    `json:"email,omitempty"`
-	Website   string    `json:"website,omitempty"`
-	Address   string    `json:"address"`
-	Mode      []string  `json:"mode"`
-	Version   int32     `json:"version"`
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"-"`
+	Name      string     `json:"name"`
+	Level     string     `json:"level"`
+	Contact   string     `json:"contact"`
+	Phone     string     `json:"phone"`
+	Email     string     `json:"email,omitempty"`
+	Website   string     `json:"website,omitempty"`
+	Address   string     `json:"address"`
+	Mode      []string   `json:"mode"`
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Version   int32      `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Highlights holds HTML-escaped snippets of the fields that matched a search
+	Highlights map[string]string `json:"highlights,omitempty"`
 }
 
 func ValidateSchool(v *validator.Validator, school *School) {
@@ -55,6 +60,16 @@ func ValidateSchool(v *validator.Validator, school *School) {
 	v.Check(len(school.Mode) <= 5, "mode", "must contain at least 5 entry")
 	v.Check(validator.Unique(school.Mode), "mode", "must not contain duplicate entries")
 
+	// The location is optional but latitude and longitude go together
+	v.Check(school.Latitude != nil || school.Longitude == nil, "latitude", "must be provided with longitude")
+	v.Check(school.Longitude != nil || school.Latitude == nil, "longitude", "must be provided with latitude")
+	if school.Latitude != nil {
+		v.Check(*school.Latitude >= -90 && *school.Latitude <= 90, "latitude", "must be between -90 and 90")
+	}
+	if school.Longitude != nil {
+		v.Check(*school.Longitude >= -180 && *school.Longitude <= 180, "longitude", "must be between -180 and 180")
+	}
+
 }
 
 // Define school model which wraps a sql.DB connsctions pool
@@ -62,24 +77,69 @@ type SchoolModel struct {
 	DB *sql.DB
 }
 
-// Insert() allows us to creae a new schools
-func (m SchoolModel) Insert(school *School) error {
-	query := `
-		INSERT INTO schools (name, level, contact, phone, email, website, address, mode)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, version
-	`
+// Insert() allows us to creae a new schools. The first revision is recorded
+// against userID in the same transaction
+func (m SchoolModel) Insert(school *School, userID int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	//Cleanup to prevent memory leaks
 	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	//Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	err = insertSchool(ctx, tx, school, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertMany() creates all the schools in a single transaction. Either every
+// school is created or none of them are
+func (m SchoolModel) InsertMany(schools []*School, userID int64) error {
+	// Allow more time since the batch can be large
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, school := range schools {
+		err = insertSchool(ctx, tx, school, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertSchool() creates a school and its first revision using tx
+func insertSchool(ctx context.Context, tx *sql.Tx, school *School, userID int64) error {
+	query := `
+		INSERT INTO schools (name, level, contact, phone, email, website, address, mode, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, version
+	`
 	//Collect the data fields into a slice
 	args := []interface{}{
 		school.Name, school.Level,
 		school.Contact, school.Phone,
 		school.Email, school.Website,
 		school.Address, pq.Array(school.Mode),
+		school.Latitude, school.Longitude,
+	}
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&school.ID, &school.CreatedAt, &school.Version)
+	if err != nil {
+		return err
 	}
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&school.ID, &school.CreatedAt, &school.Version)
+	return insertRevision(ctx, tx, school, userID, RevisionCreate)
 }
 
 //Get() alllows us to retrieve a specifi school
@@ -90,9 +150,10 @@ func (m SchoolModel) Get(id int64) (*School, error) {
 	}
 	// Create the query
 	query := `
-		SELECT id, created_at, name, level, contact, phone, email, website, address, mode, version
+		SELECT id, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude, version
 		FROM schools
 		WHERE id =  $1
+		AND deleted_at IS NULL
 	`
 	// Declare a School variable to hold the return data
 	var school School
@@ -113,6 +174,8 @@ func (m SchoolModel) Get(id int64) (*School, error) {
 		&school.Website,
 		&school.Address,
 		pq.Array(&school.Mode),
+		&school.Latitude,
+		&school.Longitude,
 		&school.Version,
 	)
 	// Handle any erros
@@ -129,21 +192,54 @@ func (m SchoolModel) Get(id int64) (*School, error) {
 	return &school, nil
 }
 
+// GetFields() retrieves only the requested fields of a specific school.
+// The id and version are always included
+func (m SchoolModel) GetFields(id int64, fields []string) (*School, error) {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	columns, dest := schoolColumns(fields, "id", "version")
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM schools
+		WHERE id = $1
+		AND deleted_at IS NULL`, columns)
+	var school School
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(dest(&school)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &school, nil
+}
+
 // Update() allow us to edit/alter a specific school
 //KEY: Go's httserver handles each request in its own goroutine
 //Avoid data races
 // Optimistic locking (version number)
 //A: apples 3 buy 3 so 0 remains
 //Apples 3 buys 2 so 1 remains
-func (m SchoolModel) Update(school *School) error {
+//The new version is recorded as a revision against userID in the same transaction
+func (m SchoolModel) Update(school *School, userID int64) error {
 	//Create a query
 	query := `
 		UPDATE schools
 		SET name = $1, level = $2, contact = $3,
 		    phone = $4, email = $5, website = $6, 
-			address = $7, mode = $8, version = version + 1
-		WHERE id = $9
-		AND version = $10
+			address = $7, mode = $8, latitude = $9, longitude = $10,
+			version = version + 1
+		WHERE id = $11
+		AND version = $12
+		AND deleted_at IS NULL
 		RETURNING version
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -159,11 +255,20 @@ func (m SchoolModel) Update(school *School) error {
 		school.Website,
 		school.Address,
 		pq.Array(school.Mode),
+		school.Latitude,
+		school.Longitude,
 		school.ID,
 		school.Version,
 	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	//Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
 	//Check for edit conflicts
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&school.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&school.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -172,58 +277,173 @@ func (m SchoolModel) Update(school *School) error {
 			return err
 		}
 	}
-	return nil
+	err = insertRevision(ctx, tx, school, userID, RevisionUpdate)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-//Delete() removes a specific school
-func (m SchoolModel) Delete(id int64) error {
+//Delete() soft deletes a specific school by setting its deleted_at time.
+// The row is only removed for good by PurgeDeleted()
+// A non-zero version means the school is only deleted if it is still at that
+// version, otherwise ErrEditConflict is returned, the same as Update()
+func (m SchoolModel) Delete(id int64, version int32, userID int64) error {
+	//Ensure that there is a valid id
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	// Only check the version when one was given
+	args := []interface{}{id}
+	versionCheck := ""
+	if version != 0 {
+		args = append(args, version)
+		versionCheck = "AND version = $2"
+	}
+	// Create the delete query. The version is bumped so cached copies of the
+	// school are no longer current
+	query := fmt.Sprintf(`
+		UPDATE schools
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1
+		%s
+		AND deleted_at IS NULL
+		RETURNING id, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude, version`, versionCheck)
+	err := m.setDeleted(query, args, userID, RevisionDelete)
+	// The school exists but is no longer at the expected version
+	if version != 0 && errors.Is(err, ErrRecordNotFound) {
+		return ErrEditConflict
+	}
+	return err
+}
+
+// Restore() clears the deleted_at time of a soft deleted school. Like
+// Delete() it bumps the version and records it as a revision
+func (m SchoolModel) Restore(id int64, userID int64) error {
 	//Ensure that there is a valid id
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// Create the delete query
 	query := `
-		DELETE FROM schools
+		UPDATE schools
+		SET deleted_at = NULL, version = version + 1
 		WHERE id = $1
+		AND deleted_at IS NOT NULL
+		RETURNING id, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude, version
 	`
+	return m.setDeleted(query, []interface{}{id}, userID, RevisionRestore)
+}
+
+// setDeleted() runs a soft delete or restore query and records the new
+// version of the school as a revision with the given action in the same
+// transaction. No rows means there was no school to delete or restore
+func (m SchoolModel) setDeleted(query string, args []interface{}, userID int64, action string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	//Cleanup to prevent memory leaks
 	defer cancel()
 
-	//Execute the query
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	// Check how many rows were affected by the delete operation
-	// call the RowsAffected() method on the result variable
-	rowsAffected, err := result.RowsAffected()
+	//Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	var school School
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&school.ID,
+		&school.CreatedAt,
+		&school.Name,
+		&school.Level,
+		&school.Contact,
+		&school.Phone,
+		&school.Email,
+		&school.Website,
+		&school.Address,
+		pq.Array(&school.Mode),
+		&school.Latitude,
+		&school.Longitude,
+		&school.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	err = insertRevision(ctx, tx, &school, userID, action)
 	if err != nil {
 		return err
 	}
-	//Check if no row were affected
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	return tx.Commit()
+}
+
+// PurgeDeleted() permanently removes schools that were soft deleted before
+// the cutoff time and returns how many were removed
+func (m SchoolModel) PurgeDeleted(cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM schools
+		WHERE deleted_at IS NOT NULL
+		AND deleted_at < $1
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return result.RowsAffected()
+}
+
+// SchoolSearch holds the criteria used to pick which schools are listed.
+// Soft deleted schools are only included when IncludeDeleted is true.
+// FuzzyName also matches names that are similar to Name, allowing for typos.
+// Query is a free-text search over the name, address and contact
+type SchoolSearch struct {
+	Name           string
+	FuzzyName      bool
+	Level          string
+	Mode           []string
+	Query          string
+	IncludeDeleted bool
 }
 
-// the GetAll() method returns a list of all the shcools sorted by id
-func (m SchoolModel) GetAll(name string, level string, mode []string, filters Filters) ([]*School, Metadata, error) {
+// The where() method returns the WHERE clause for the search along with its
+// arguments, which start at $1
+func (s SchoolSearch) where() (string, []interface{}) {
+	clause := `
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR ($5 AND $1 <% name) OR $1 = '')
+		AND (to_tsvector('simple', level) @@ plainto_tsquery('simple', $2) OR $2 = '')
+		AND (mode @> $3 OR $3 = '{}' )
+		AND (deleted_at IS NULL OR $4)
+		AND (search_vector @@ plainto_tsquery('simple', $6) OR $6 = '')`
+	args := []interface{}{s.Name, s.Level, pq.Array(s.Mode), s.IncludeDeleted, s.FuzzyName, s.Query}
+	return clause, args
+}
+
+// the GetAll() method returns a page of the shcools that match the search
+func (m SchoolModel) GetAll(search SchoolSearch, filters Filters) ([]*School, Metadata, error) {
+	where, args := search.where()
+	columns, dest := schoolColumns(filters.Fields, "id")
+	// Highlight the matched terms if there are any
+	if headlines := search.headlineColumns(); headlines != "" {
+		columns += ", " + headlines
+	}
 	// Construct the query
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, created_at, name, level, contact, phone, email, website, address, mode, version
+		SELECT COUNT(*) OVER(), %s
 		FROM schools
-		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (to_tsvector('simple', level) @@ plainto_tsquery('simple', $2) OR $2 = '')
-		AND (mode @> $3 OR $3 = '{}' )
-		ORDER BY %s %s, id ASC
-		LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortOrder())
+		%s
+		ORDER BY %s, id ASC
+		LIMIT $%d OFFSET $%d`, columns, where, search.orderBy(filters), len(args)+1, len(args)+2)
 
 	//Create a 3-second-timeout context
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	args := []interface{}{name, level, pq.Array(mode), filters.limit(), filters.offset()}
+	args = append(args, filters.limit(), filters.offset())
 	//Execute the query
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -237,24 +457,19 @@ func (m SchoolModel) GetAll(name string, level string, mode []string, filters Fi
 	// Iterate over the rows in the result set
 	for rows.Next() {
 		var school School
+		headlines := make([]string, len(highlightFields))
+		targets := append([]interface{}{&totalRecords}, dest(&school)...)
+		if search.HasText() {
+			for i := range headlines {
+				targets = append(targets, &headlines[i])
+			}
+		}
 		// Scan the values from the row into the School
-		err := rows.Scan(
-			&totalRecords,
-			&school.ID,
-			&school.CreatedAt,
-			&school.Name,
-			&school.Level,
-			&school.Contact,
-			&school.Phone,
-			&school.Email,
-			&school.Website,
-			&school.Address,
-			pq.Array(&school.Mode),
-			&school.Version,
-		)
+		err := rows.Scan(targets...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
+		school.setHighlights(headlines)
 		// Add the school tour slice
 		schools = append(schools, &school)
 	}
@@ -266,3 +481,162 @@ func (m SchoolModel) GetAll(name string, level string, mode []string, filters Fi
 	// Return the slice of schools
 	return schools, metadata, nil
 }
+
+// The GetAllCursor() method returns the page of schools that follows
+// filters.Cursor. Unlike GetAll() it never counts or skips rows, so every
+// page costs the same and rows inserted meanwhile don't shift the pages
+func (m SchoolModel) GetAllCursor(search SchoolSearch, filters Filters) ([]*School, CursorMetadata, error) {
+	where, args := search.where()
+	keyset, keysetArgs, err := filters.keyset(len(args) + 1)
+	if err != nil {
+		return nil, CursorMetadata{}, err
+	}
+	args = append(args, keysetArgs...)
+	// The next cursor needs the id and the sort column of the last row
+	columns, dest := schoolColumns(filters.Fields, "id", filters.sortColumn())
+	// Highlight the matched terms if there are any
+	if headlines := search.headlineColumns(); headlines != "" {
+		columns += ", " + headlines
+	}
+	// Fetch one extra row to find out if there is a next page
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM schools
+		%s
+		AND %s
+		ORDER BY %s, id ASC
+		LIMIT $%d`, columns, where, keyset, search.orderBy(filters), len(args)+1)
+	args = append(args, filters.limit()+1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, CursorMetadata{}, err
+	}
+	defer rows.Close()
+	schools := []*School{}
+	for rows.Next() {
+		var school School
+		headlines := make([]string, len(highlightFields))
+		targets := dest(&school)
+		if search.HasText() {
+			for i := range headlines {
+				targets = append(targets, &headlines[i])
+			}
+		}
+		err := rows.Scan(targets...)
+		if err != nil {
+			return nil, CursorMetadata{}, err
+		}
+		school.setHighlights(headlines)
+		schools = append(schools, &school)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, CursorMetadata{}, err
+	}
+	metadata := CursorMetadata{PageSize: filters.PageSize}
+	if len(schools) > filters.limit() {
+		schools = schools[:filters.limit()]
+		metadata.NextCursor = filters.nextCursor(schools[len(schools)-1])
+	}
+	return schools, metadata, nil
+}
+
+// Exports stream the whole result set, so they get far more time than a page
+const ExportTimeout = 5 * time.Minute
+
+// The Export() method runs the query for every school that matches the
+// search, in the order given by the filters. Paging is ignored so the whole
+// result set is streamed through the returned SchoolRows, which must be
+// closed. The first row is fetched before Export() returns, so a failing
+// query is reported here, before the caller has written anything
+func (m SchoolModel) Export(search SchoolSearch, filters Filters) (*SchoolRows, error) {
+	where, args := search.where()
+	query := fmt.Sprintf(`
+		SELECT id, created_at, name, level, contact, phone, email, website, address, mode, latitude, longitude, version, deleted_at
+		FROM schools
+		%s
+		ORDER BY %s, id ASC`, where, search.orderBy(filters))
+
+	ctx, cancel := context.WithTimeout(context.Background(), ExportTimeout)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	sr := &SchoolRows{rows: rows, cancel: cancel}
+	// Fetch the first row now and hand it out on the first call to Next()
+	sr.peeked = sr.scan()
+	if sr.err != nil {
+		sr.Close()
+		return nil, sr.err
+	}
+	sr.hasPeeked = true
+	return sr, nil
+}
+
+// SchoolRows iterates over the schools of an export
+type SchoolRows struct {
+	rows      *sql.Rows
+	cancel    context.CancelFunc
+	school    School
+	peeked    bool //whether the first row was found
+	hasPeeked bool //whether the first row is still to be handed out
+	err       error
+}
+
+// Next() moves to the next school, returning false at the end or on error
+func (sr *SchoolRows) Next() bool {
+	if sr.hasPeeked {
+		sr.hasPeeked = false
+		return sr.peeked
+	}
+	return sr.scan()
+}
+
+// The scan() method reads the next row into sr.school
+func (sr *SchoolRows) scan() bool {
+	if sr.err != nil || !sr.rows.Next() {
+		if sr.err == nil {
+			sr.err = sr.rows.Err()
+		}
+		return false
+	}
+	sr.school = School{}
+	sr.err = sr.rows.Scan(
+		&sr.school.ID,
+		&sr.school.CreatedAt,
+		&sr.school.Name,
+		&sr.school.Level,
+		&sr.school.Contact,
+		&sr.school.Phone,
+		&sr.school.Email,
+		&sr.school.Website,
+		&sr.school.Address,
+		pq.Array(&sr.school.Mode),
+		&sr.school.Latitude,
+		&sr.school.Longitude,
+		&sr.school.Version,
+		&sr.school.DeletedAt,
+	)
+	return sr.err == nil
+}
+
+// School() returns the current school. It is only valid until the next call
+// to Next()
+func (sr *SchoolRows) School() *School {
+	return &sr.school
+}
+
+// Err() returns the error that stopped the iteration, if any
+func (sr *SchoolRows) Err() error {
+	return sr.err
+}
+
+// Close() releases the rows and the query context
+func (sr *SchoolRows) Close() error {
+	defer sr.cancel()
+	return sr.rows.Close()
+}
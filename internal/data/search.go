@@ -0,0 +1,82 @@
+// Filename: internal/data/search.go
+
+package data
+
+import (
+	"html"
+	"strings"
+)
+
+// ts_headline() copies the stored text into its output as is, so school
+// text like "<script>" would reach the client unescaped. Matched terms are
+// wrapped in control characters instead of tags, and setHighlights()
+// escapes the headline before turning them into <mark> tags
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// The options passed to ts_headline(). Short fields are returned whole
+const headlineOptions = `StartSel="` + headlineStart + `", StopSel="` + headlineStop + `", HighlightAll=true`
+
+// HasText() reports whether the search has any full-text terms to rank
+// and highlight
+func (s SchoolSearch) HasText() bool {
+	return s.Name != "" || s.Level != "" || s.Query != ""
+}
+
+// The rank() method returns the SQL expression that scores how well a row
+// matches the search. It refers to the arguments returned by where()
+func (s SchoolSearch) rank() string {
+	return `(
+		ts_rank(to_tsvector('simple', name), plainto_tsquery('simple', $1)) +
+		ts_rank(to_tsvector('simple', level), plainto_tsquery('simple', $2)) +
+		ts_rank(search_vector, plainto_tsquery('simple', $6))
+	)`
+}
+
+// The orderBy() method returns the ORDER BY expression for the filters.
+// Sorting by relevance puts the best matches first
+func (s SchoolSearch) orderBy(f Filters) string {
+	if f.Sort == "relevance" {
+		return s.rank() + " DESC"
+	}
+	return f.sortColumn() + " " + f.sortOrder()
+}
+
+// The fields that can be highlighted, in the order of headlineColumns()
+var highlightFields = []string{"name", "level", "address", "contact"}
+
+// The headlineColumns() method returns the ts_headline() select list for
+// the highlightFields, or an empty string if there is nothing to highlight
+func (s SchoolSearch) headlineColumns() string {
+	if !s.HasText() {
+		return ""
+	}
+	return `
+		ts_headline('simple', name, plainto_tsquery('simple', $1) || plainto_tsquery('simple', $6), '` + headlineOptions + `'),
+		ts_headline('simple', level, plainto_tsquery('simple', $2), '` + headlineOptions + `'),
+		ts_headline('simple', address, plainto_tsquery('simple', $6), '` + headlineOptions + `'),
+		ts_headline('simple', contact, plainto_tsquery('simple', $6), '` + headlineOptions + `')`
+}
+
+// The setHighlights() method keeps the headlines that actually contain a match
+func (school *School) setHighlights(headlines []string) {
+	for i, headline := range headlines {
+		if !strings.Contains(headline, headlineStart) {
+			continue
+		}
+		if school.Highlights == nil {
+			school.Highlights = make(map[string]string)
+		}
+		school.Highlights[highlightFields[i]] = markHeadline(headline)
+	}
+}
+
+// The markHeadline() function HTML-escapes a headline and then wraps the
+// matched terms in <mark> tags, so the <mark> tags are the only markup in it
+func markHeadline(headline string) string {
+	headline = html.EscapeString(headline)
+	headline = strings.ReplaceAll(headline, headlineStart, "<mark>")
+	return strings.ReplaceAll(headline, headlineStop, "</mark>")
+}
@@ -0,0 +1,29 @@
+// Filename: internal/data/search_test.go
+
+package data
+
+import (
+	"testing"
+)
+
+func TestSetHighlights(t *testing.T) {
+	school := &School{}
+	school.setHighlights([]string{
+		"\x02Belize\x03 <script>alert(1)</script> High",
+		"Secondary",
+		"\"\x02Main\x03\" & Street",
+		"",
+	})
+	want := map[string]string{
+		"name":    "<mark>Belize</mark> &lt;script&gt;alert(1)&lt;/script&gt; High",
+		"address": "&#34;<mark>Main</mark>&#34; &amp; Street",
+	}
+	if len(school.Highlights) != len(want) {
+		t.Fatalf("got highlights %v; want %v", school.Highlights, want)
+	}
+	for field, headline := range want {
+		if got := school.Highlights[field]; got != headline {
+			t.Errorf("highlight %q = %q; want %q", field, got, headline)
+		}
+	}
+}
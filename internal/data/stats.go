@@ -0,0 +1,96 @@
+// Filename: internal/data/stats.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MissingStat counts the schools without a value for a field
+type MissingStat struct {
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// SchoolStats holds the aggregate numbers of the directory. They come from
+// the school_stats materialized view, so they are as of RefreshedAt
+type SchoolStats struct {
+	Total           int                    `json:"total"`
+	ByLevel         map[string]int         `json:"by_level"`
+	ByMode          map[string]int         `json:"by_mode"`
+	CreatedPerMonth map[string]int         `json:"created_per_month"`
+	Missing         map[string]MissingStat `json:"missing"`
+	RefreshedAt     time.Time              `json:"refreshed_at"`
+}
+
+// Define the school stats model
+type SchoolStatsModel struct {
+	DB *sql.DB
+}
+
+// Get() reads the stats from the materialized view
+func (m SchoolStatsModel) Get() (*SchoolStats, error) {
+	query := `
+		SELECT dimension, value, count, refreshed_at
+		FROM school_stats
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &SchoolStats{
+		ByLevel:         make(map[string]int),
+		ByMode:          make(map[string]int),
+		CreatedPerMonth: make(map[string]int),
+		Missing:         make(map[string]MissingStat),
+	}
+	for rows.Next() {
+		var dimension, value string
+		var count int
+		err := rows.Scan(&dimension, &value, &count, &stats.RefreshedAt)
+		if err != nil {
+			return nil, err
+		}
+		switch dimension {
+		case "total":
+			stats.Total = count
+		case "level":
+			stats.ByLevel[value] = count
+		case "mode":
+			stats.ByMode[value] = count
+		case "month":
+			stats.CreatedPerMonth[value] = count
+		case "missing":
+			stats.Missing[value] = MissingStat{Count: count}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// The shares can only be worked out once the total is known
+	for field, missing := range stats.Missing {
+		if stats.Total > 0 {
+			missing.Share = float64(missing.Count) / float64(stats.Total)
+		}
+		stats.Missing[field] = missing
+	}
+	return stats, nil
+}
+
+// Refresh() recomputes the materialized view without blocking readers
+func (m SchoolStatsModel) Refresh() error {
+	query := `REFRESH MATERIALIZED VIEW CONCURRENTLY school_stats`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,55 @@
+// Filename: internal/data/suggest.go
+
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// A Suggestion is a school name that matches what the client has typed so far
+type Suggestion struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest() returns up to limit school names that look like q, best match
+// first. Names are matched by trigram word similarity so partial words and
+// typos still match, with substring matches as a fallback for short input
+func (m SchoolModel) Suggest(q string, limit int) ([]*Suggestion, error) {
+	query := `
+		SELECT id, name, word_similarity($1, name) AS score
+		FROM schools
+		WHERE deleted_at IS NULL
+		AND ($1 <% name OR name ILIKE '%' || $2 || '%')
+		ORDER BY score DESC, name ASC, id ASC
+		LIMIT $3
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, likeEscaper.Replace(q), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Score)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
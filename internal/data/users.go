@@ -4,6 +4,7 @@ package data
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"time"
@@ -16,6 +17,9 @@ var (
 	ErrDuplicateEmail = errors.New("duplicate email")
 )
 
+// AnonymousUser represents a client that has not authenticated
+var AnonymousUser = &User{}
+
 type User struct {
 	ID        int64     `json:"id"`
 	CreateAt  time.Time `json:"created_at"`
@@ -26,6 +30,11 @@ type User struct {
 	Version   int       `json:"-"`
 }
 
+// IsAnonymous() checks if the user is the AnonymousUser
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
 //Create a customer password type
 type password struct {
 	plaintext *string
@@ -75,7 +84,7 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) >= 500, "password", "must not be more than 500 bytes long")
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
 	// Validate the email
 	ValidateEmail(v, user.Email)
 	//validate the password
@@ -93,13 +102,58 @@ type UserModel struct {
 	DB *sql.DB
 }
 
-//Create a new user
+// Create a new user
 func (m UserModel) Insert(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertUser(ctx, m.DB, user)
+}
+
+// Register() creates a new user with the permission codes and an activation
+// token in a single transaction. Either all three are stored or none are, so
+// an account can never exist without a way to activate it
+func (m UserModel) Register(user *User, ttl time.Duration, codes ...string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	//Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	err = insertUser(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+	err = addPermissions(ctx, tx, user.ID, codes)
+	if err != nil {
+		return nil, err
+	}
+	token, err := generateToken(user.ID, ttl, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+	err = insertToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// insertUser() creates a user using db
+func insertUser(ctx context.Context, db execQueryer, user *User) error {
 	//Creaet our query
 	query := `
 	INSERT INTO users (name, email, password_hash, activated)
-	VALUE ($1, $2, $3, $4)
-	RETURNING id, create_at, version
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, version
 	`
 	args := []interface{}{
 		user.Name,
@@ -107,10 +161,7 @@ func (m UserModel) Insert(user *User) error {
 		user.Password.hash,
 		user.Activated,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreateAt, &user.Version)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreateAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -125,7 +176,7 @@ func (m UserModel) Insert(user *User) error {
 // Get user based on their email
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, create_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, version
 		FROM users
 		WHERE email = $1
 	`
@@ -154,7 +205,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 }
 
 // The clinet can update their information
-func (m UserModel) update(user *User) error {
+func (m UserModel) Update(user *User) error {
 	query := `
 		UPDATE users
 		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
@@ -175,8 +226,10 @@ func (m UserModel) update(user *User) error {
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "user_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
 		default:
 			return err
 		}
@@ -184,3 +237,42 @@ func (m UserModel) update(user *User) error {
 
 	return nil
 }
+
+// GetForToken() returns the user that owns a valid token of the given scope
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	// Calculate the SHA-256 hash of the plaintext token
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3
+	`
+	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreateAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
@@ -3,10 +3,14 @@
 package jsonlog
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,76 +20,166 @@ type Level int8
 
 // Levels start at zero
 const (
-	LevelInfo  Level = iota //value is 0
-	LevelError              //value is 1
-	LevelFatal              //value is 2
-	LevelOff                //value is 3
+	LevelDebug Level = iota //value is 0
+	LevelInfo               //value is 1
+	LevelWarn               //value is 2
+	LevelError              //value is 3
+	LevelFatal              //value is 4
+	LevelOff                //value is 5
 )
 
 // The severity levels as a human-readable friendly formant
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+// The ParseLevel() function turns a level name such as "warn" into a Level
+func ParseLevel(name string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("jsonlog: unknown level %q", name)
+}
+
+// Set() lets a Level be used as a command-line flag
+func (l *Level) Set(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
 //Define a custom logger
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	mu       sync.Mutex
+	out         io.Writer
+	minLevel    Level
+	mu          *sync.Mutex //shared with the child loggers
+	properties  map[string]interface{}
+	handler     slog.Handler //when set, entries are sent here instead of out
+	tracePolicy TracePolicy  //decides which entries get a stack trace
+	redactor    *Redactor    //masks sensitive values, nil turns it off
 }
 
 // The New() funcjtjion creates a new instance of Logger
 func New(out io.Writer, minLevel Level) *Logger {
 	return &Logger{
-		out:      out,
-		minLevel: minLevel,
+		out:         out,
+		minLevel:    minLevel,
+		mu:          &sync.Mutex{},
+		tracePolicy: TraceAtLevel(LevelError),
 	}
 }
 
+// With() returns a child logger that adds the properties to every entry.
+// Properties passed to the print methods take precedence
+func (l *Logger) With(properties map[string]interface{}) *Logger {
+	child := *l
+	child.properties = merge(l.properties, properties)
+	return &child
+}
+
+// The properties stored in a context by NewContext() live under this key
+type contextKey struct{}
+
+// The NewContext() function returns a copy of ctx carrying properties, such
+// as a request ID, that WithContext() adds to the log entries
+func NewContext(ctx context.Context, properties map[string]interface{}) context.Context {
+	existing, _ := ctx.Value(contextKey{}).(map[string]interface{})
+	return context.WithValue(ctx, contextKey{}, merge(existing, properties))
+}
+
+// WithContext() returns a child logger that adds the properties stored in ctx
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	properties, _ := ctx.Value(contextKey{}).(map[string]interface{})
+	if len(properties) == 0 {
+		return l
+	}
+	return l.With(properties)
+}
+
 // Helper methods
-func (l *Logger) PrintInfo(message string, properties map[string]string) {
+func (l *Logger) PrintDebug(message string, properties map[string]interface{}) {
+	l.print(LevelDebug, message, properties)
+}
+
+func (l *Logger) PrintInfo(message string, properties map[string]interface{}) {
 	l.print(LevelInfo, message, properties)
 }
 
-func (l *Logger) PrintError(err error, properties map[string]string) {
+func (l *Logger) PrintWarn(message string, properties map[string]interface{}) {
+	l.print(LevelWarn, message, properties)
+}
+
+func (l *Logger) PrintError(err error, properties map[string]interface{}) {
 	l.print(LevelError, err.Error(), properties)
 }
 
-func (l *Logger) PrintFatal(err error, properties map[string]string) {
+func (l *Logger) PrintFatal(err error, properties map[string]interface{}) {
 	l.print(LevelFatal, err.Error(), properties)
 	os.Exit(1)
 }
 
-func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
+func (l *Logger) print(level Level, message string, properties map[string]interface{}) (int, error) {
+	return l.printAt(level, time.Now(), message, properties)
+}
+
+// The printAt() method is print() for an entry with a given time
+func (l *Logger) printAt(level Level, t time.Time, message string, properties map[string]interface{}) (int, error) {
 	// Ensure serverity level is at least the minimum
 	if level < l.minLevel {
 		return 0, nil
 	}
+	properties = encodable(merge(l.properties, properties))
+	// Mask anything sensitive before the entry leaves the logger
+	if l.redactor != nil {
+		message = l.redactor.String(message)
+		properties = l.redactor.Properties(properties)
+	}
+	// Should we include the stack trace
+	var trace string
+	if l.tracePolicy != nil && l.tracePolicy(level, message) {
+		trace = string(debug.Stack())
+	}
+	if l.handler != nil {
+		return 0, l.handle(level, t, message, properties, trace)
+	}
+	return l.write(level, t, message, properties, trace)
+}
+
+// The write() method encodes an entry and writes it to the output
+func (l *Logger) write(level Level, t time.Time, message string, properties map[string]interface{}, trace string) (int, error) {
 	// Create a struct for holding the log entry data
 	data := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
+		Level      string                 `json:"level"`
+		Time       string                 `json:"time"`
+		Message    string                 `json:"message"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+		Trace      string                 `json:"trace,omitempty"`
 	}{
 		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
+		Time:       t.UTC().Format(time.RFC3339),
 		Message:    message,
 		Properties: properties,
-	}
-	// Should we include the stack trace
-	if level >= LevelError {
-		data.Trace = string(debug.Stack())
+		Trace:      trace,
 	}
 	// Enclode the log entry to JSON
 	var entry []byte
@@ -103,3 +197,34 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 func (l *Logger) Write(message []byte) (n int, err error) {
 	return l.print(LevelError, string(message), nil)
 }
+
+// The merge() function returns a new map with the properties of b layered
+// over those of a
+func merge(a, b map[string]interface{}) map[string]interface{} {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(a)+len(b))
+	for key, value := range a {
+		merged[key] = value
+	}
+	for key, value := range b {
+		merged[key] = value
+	}
+	return merged
+}
+
+// The encodable() function converts the values that encoding/json does not
+// write in a readable way. Durations become strings such as "1.5s" rather
+// than nanoseconds, and errors become their message rather than "{}"
+func encodable(properties map[string]interface{}) map[string]interface{} {
+	for key, value := range properties {
+		switch v := value.(type) {
+		case time.Duration:
+			properties[key] = v.String()
+		case error:
+			properties[key] = v.Error()
+		}
+	}
+	return properties
+}
@@ -0,0 +1,163 @@
+// Filename: internal/jsonlog/redact.go
+
+package jsonlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"schools.federicorosado.net/internal/validator"
+)
+
+// Redacted values are replaced with this
+const RedactedMask = "[REDACTED]"
+
+// DefaultRedactKeys are the property keys whose values are always masked
+var DefaultRedactKeys = []string{
+	"authorization",
+	"cookie",
+	"email",
+	"password",
+	"phone",
+	"secret",
+	"token",
+}
+
+// DefaultRedactPatterns find emails, phone numbers and tokens inside messages
+// and property values. The validator patterns match whole strings, so they
+// are used here without their anchors
+var DefaultRedactPatterns = []*regexp.Regexp{
+	Unanchored(validator.EmailRX),
+	Unanchored(validator.PhoneRX),
+	// Bearer tokens in headers that end up in error messages
+	regexp.MustCompile(`(?i)\bbearer\s+\S+`),
+	// Activation, authentication and password reset tokens are 26 base-32
+	// characters
+	regexp.MustCompile(`\b[A-Z2-7]{26}\b`),
+}
+
+// The Unanchored() function returns a copy of a pattern with a leading ^ and
+// a trailing $ removed, so it finds matches anywhere in a string
+func Unanchored(rx *regexp.Regexp) *regexp.Regexp {
+	expr := strings.TrimPrefix(rx.String(), "^")
+	expr = strings.TrimSuffix(expr, "$")
+	return regexp.MustCompile(expr)
+}
+
+// A Redactor masks sensitive values before an entry is written
+type Redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// The NewRedactor() function returns a Redactor that masks the whole value of
+// the given property keys, which are matched case-insensitively, and every
+// match of the patterns in messages and string values
+func NewRedactor(keys []string, patterns []*regexp.Regexp) *Redactor {
+	r := &Redactor{
+		keys:     make(map[string]bool, len(keys)),
+		patterns: patterns,
+	}
+	for _, key := range keys {
+		r.keys[strings.ToLower(strings.TrimSpace(key))] = true
+	}
+	return r
+}
+
+// The DefaultRedactor() function returns a Redactor with the default keys
+// and patterns
+func DefaultRedactor() *Redactor {
+	return NewRedactor(DefaultRedactKeys, DefaultRedactPatterns)
+}
+
+// String() masks every match of the patterns in s
+func (r *Redactor) String(s string) string {
+	for _, rx := range r.patterns {
+		s = rx.ReplaceAllLiteralString(s, RedactedMask)
+	}
+	return s
+}
+
+// The redactKey() method reports whether the value of a key is masked. Keys
+// from slog groups are matched on their last part, so "user.email" counts as
+// "email"
+func (r *Redactor) redactKey(key string) bool {
+	key = strings.ToLower(key)
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	return r.keys[key]
+}
+
+// Properties() masks the values of sensitive keys and the pattern matches in
+// string values. Nested maps and slices are searched too, and values with a
+// String() method are masked as their string. The properties are changed in
+// place, but nested maps and slices are copied since they may belong to the
+// caller
+func (r *Redactor) Properties(properties map[string]interface{}) map[string]interface{} {
+	for key, value := range properties {
+		if r.redactKey(key) {
+			properties[key] = RedactedMask
+			continue
+		}
+		properties[key] = r.value(value)
+	}
+	return properties
+}
+
+// The value() method returns a masked copy of a property value
+func (r *Redactor) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case time.Time:
+		// Times are Stringers but are better left in their JSON form
+		return v
+	case error:
+		return r.String(v.Error())
+	case fmt.Stringer:
+		return r.String(v.String())
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if r.redactKey(key) {
+				masked[key] = RedactedMask
+				continue
+			}
+			masked[key] = r.value(value)
+		}
+		return masked
+	case map[string]string:
+		masked := make(map[string]string, len(v))
+		for key, value := range v {
+			if r.redactKey(key) {
+				masked[key] = RedactedMask
+				continue
+			}
+			masked[key] = r.String(value)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, value := range v {
+			masked[i] = r.value(value)
+		}
+		return masked
+	case []string:
+		masked := make([]string, len(v))
+		for i, value := range v {
+			masked[i] = r.String(value)
+		}
+		return masked
+	}
+	return value
+}
+
+// SetRedactor() sets the Redactor used for every entry, nil turns redaction
+// off. It should be called before the logger is used, and child loggers keep
+// the Redactor they were created with
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.redactor = r
+}
@@ -0,0 +1,163 @@
+// Filename: internal/jsonlog/slog.go
+
+package jsonlog
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LevelFatal has no slog equivalent, so it is sent as a level above ERROR
+const slogLevelFatal = slog.LevelError + 4
+
+// The fromSlogLevel() function maps a slog level onto the nearest of ours at
+// or below it
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < slogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// The toSlogLevel() function maps one of our levels onto a slog level
+func toSlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+// Handler is a slog.Handler that writes entries through a Logger, so code
+// using log/slog produces the same JSON as the Print methods. Attributes
+// become properties, and attributes inside groups are keyed as "group.key"
+type Handler struct {
+	logger *Logger
+	prefix string //the open groups, each followed by a dot
+}
+
+// The NewHandler() function returns a slog.Handler that writes to l
+func NewHandler(l *Logger) *Handler {
+	return &Handler{logger: l}
+}
+
+// Slog() returns a *slog.Logger that writes through l
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(NewHandler(l))
+}
+
+// ErrorLog() returns a *log.Logger that writes each line through l at the
+// given level. It is meant for http.Server.ErrorLog
+func (l *Logger) ErrorLog(level Level) *log.Logger {
+	return slog.NewLogLogger(NewHandler(l), toSlogLevel(level))
+}
+
+// Enabled() reports whether the logger writes entries at the level
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return fromSlogLevel(level) >= h.logger.minLevel
+}
+
+// Handle() writes the record. Properties added to ctx with NewContext() are
+// included, and the record's own attributes take precedence over them
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	properties := make(map[string]interface{}, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(properties, h.prefix, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	// Unlike PrintFatal(), a FATAL record from slog does not exit
+	_, err := h.logger.WithContext(ctx).printAt(fromSlogLevel(r.Level), t, r.Message, properties)
+	return err
+}
+
+// WithAttrs() returns a handler whose entries include the attributes
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	properties := make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		addAttr(properties, h.prefix, a)
+	}
+	return &Handler{logger: h.logger.With(properties), prefix: h.prefix}
+}
+
+// WithGroup() returns a handler that keys later attributes under the group
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+// The addAttr() function adds an attribute to the properties, flattening
+// groups into dotted keys
+func addAttr(properties map[string]interface{}, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// An unnamed group is inlined into its parent
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(properties, prefix, ga)
+		}
+		return
+	}
+	properties[prefix+a.Key] = a.Value.Any()
+}
+
+// The NewFromHandler() function returns a Logger that sends its entries to a
+// slog.Handler instead of writing them itself. This lets code written
+// against Logger log through any slog backend
+func NewFromHandler(h slog.Handler, minLevel Level) *Logger {
+	l := New(nil, minLevel)
+	l.handler = h
+	return l
+}
+
+// The handle() method sends an entry to the slog handler. Properties are
+// added in key order so the output does not depend on map iteration
+func (l *Logger) handle(level Level, t time.Time, message string, properties map[string]interface{}, trace string) error {
+	ctx := context.Background()
+	slogLevel := toSlogLevel(level)
+	if !l.handler.Enabled(ctx, slogLevel) {
+		return nil
+	}
+	r := slog.NewRecord(t, slogLevel, message, 0)
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, properties[key]))
+	}
+	if trace != "" {
+		r.AddAttrs(slog.String("trace", strings.TrimSpace(trace)))
+	}
+	return l.handler.Handle(ctx, r)
+}
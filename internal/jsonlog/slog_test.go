@@ -0,0 +1,165 @@
+// Filename: internal/jsonlog/slog_test.go
+
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// testEntry is the part of a log entry the tests look at
+type testEntry struct {
+	Level      string                 `json:"level"`
+	Message    string                 `json:"message"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// The readEntries() function decodes every entry written to buf
+func readEntries(t *testing.T, buf *bytes.Buffer) []testEntry {
+	t.Helper()
+	entries := []testEntry{}
+	dec := json.NewDecoder(buf)
+	for {
+		var entry testEntry
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestFromSlogLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  Level
+	}{
+		{slog.LevelDebug - 4, LevelDebug},
+		{slog.LevelDebug, LevelDebug},
+		{slog.LevelInfo, LevelInfo},
+		{slog.LevelInfo + 2, LevelInfo},
+		{slog.LevelWarn, LevelWarn},
+		{slog.LevelError, LevelError},
+		{slog.LevelError + 3, LevelError},
+		{slog.LevelError + 4, LevelFatal},
+		{slog.LevelError + 8, LevelFatal},
+	}
+	for _, tt := range tests {
+		if got := fromSlogLevel(tt.level); got != tt.want {
+			t.Errorf("fromSlogLevel(%v) = %v; want %v", tt.level, got, tt.want)
+		}
+	}
+	// Our levels survive the trip through slog
+	for l := LevelDebug; l <= LevelFatal; l++ {
+		if got := fromSlogLevel(toSlogLevel(l)); got != l {
+			t.Errorf("fromSlogLevel(toSlogLevel(%v)) = %v", l, got)
+		}
+	}
+}
+
+func TestHandlerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelWarn).Slog()
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("INFO is enabled below the minimum level")
+	}
+	logger.Info("skipped")
+	logger.Warn("kept")
+	logger.Log(context.Background(), slog.LevelError+4, "fatal from slog")
+	entries := readEntries(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries; want 2", len(entries))
+	}
+	if entries[0].Level != "WARN" || entries[0].Message != "kept" {
+		t.Errorf("got %+v; want a WARN entry", entries[0])
+	}
+	if entries[1].Level != "FATAL" {
+		t.Errorf("got level %s; want FATAL", entries[1].Level)
+	}
+}
+
+func TestHandlerGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo).Slog()
+	logger.WithGroup("request").With("id", "abc").Info("done",
+		slog.Int("status", 200),
+		slog.Group("user", slog.String("name", "jane")),
+		// An unnamed group is inlined and an empty group is dropped
+		slog.Group("", slog.Int("bytes", 512)),
+		slog.Group("empty"),
+	)
+	entries := readEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries; want 1", len(entries))
+	}
+	want := map[string]interface{}{
+		"request.id":        "abc",
+		"request.status":    float64(200),
+		"request.user.name": "jane",
+		"request.bytes":     float64(512),
+	}
+	if !reflect.DeepEqual(entries[0].Properties, want) {
+		t.Errorf("got properties %v; want %v", entries[0].Properties, want)
+	}
+}
+
+func TestHandlerWithDoesNotChangeParent(t *testing.T) {
+	var buf bytes.Buffer
+	parent := New(&buf, LevelInfo).Slog()
+	child := parent.With("component", "mailer").WithGroup("smtp")
+	child.Info("sent", "port", 25)
+	parent.Info("plain")
+	child.Info("again")
+	entries := readEntries(t, &buf)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries; want 3", len(entries))
+	}
+	tests := []map[string]interface{}{
+		{"component": "mailer", "smtp.port": float64(25)},
+		nil,
+		{"component": "mailer"},
+	}
+	for i, want := range tests {
+		if !reflect.DeepEqual(entries[i].Properties, want) {
+			t.Errorf("entry %d properties = %v; want %v", i, entries[i].Properties, want)
+		}
+	}
+}
+
+// Properties from the context are included, and the record's own attributes
+// win over them
+func TestHandlerContextProperties(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo).Slog()
+	ctx := NewContext(context.Background(), map[string]interface{}{"request_id": "r1", "route": "old"})
+	logger.InfoContext(ctx, "done", "route", "/v1/schools")
+	entries := readEntries(t, &buf)
+	want := map[string]interface{}{"request_id": "r1", "route": "/v1/schools"}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Properties, want) {
+		t.Errorf("got %+v; want properties %v", entries, want)
+	}
+}
+
+func TestErrorLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, LevelInfo).ErrorLog(LevelWarn).Print("http: TLS handshake error from 10.0.0.1:4000: EOF")
+	entries := readEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries; want 1", len(entries))
+	}
+	if entries[0].Level != "WARN" {
+		t.Errorf("got level %s; want WARN", entries[0].Level)
+	}
+	if entries[0].Message != "http: TLS handshake error from 10.0.0.1:4000: EOF" {
+		t.Errorf("got message %q", entries[0].Message)
+	}
+}
@@ -0,0 +1,68 @@
+// Filename: internal/jsonlog/trace.go
+
+package jsonlog
+
+import (
+	"sync"
+	"time"
+)
+
+// A TracePolicy decides whether an entry gets a stack trace. Capturing one
+// is costly, so most entries should not
+type TracePolicy func(level Level, message string) bool
+
+// TraceNever never captures stack traces
+func TraceNever(Level, string) bool {
+	return false
+}
+
+// The TraceAtLevel() function returns a policy that traces every entry at or
+// above the level. TraceAtLevel(LevelError) is the default
+func TraceAtLevel(min Level) TracePolicy {
+	return func(level Level, _ string) bool {
+		return level >= min
+	}
+}
+
+// Remembered messages are forgotten once there are this many, so a stream of
+// unique messages cannot grow the map without bound
+const maxTracedMessages = 1024
+
+// The TraceOncePer() function returns a policy that traces entries at or
+// above the level, but only the first entry with a given message in each
+// interval. Repeated errors then log one trace rather than one per entry
+func TraceOncePer(min Level, interval time.Duration) TracePolicy {
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]time.Time)
+	)
+	return func(level Level, message string) bool {
+		if level < min {
+			return false
+		}
+		now := time.Now()
+		mu.Lock()
+		defer mu.Unlock()
+		if last, ok := seen[message]; ok && now.Sub(last) < interval {
+			return false
+		}
+		if len(seen) >= maxTracedMessages {
+			for msg, last := range seen {
+				if now.Sub(last) >= interval {
+					delete(seen, msg)
+				}
+			}
+			if len(seen) >= maxTracedMessages {
+				seen = make(map[string]time.Time)
+			}
+		}
+		seen[message] = now
+		return true
+	}
+}
+
+// SetTracePolicy() replaces the trace policy. It should be called before the
+// logger is used, and child loggers keep the policy they were created with
+func (l *Logger) SetTracePolicy(policy TracePolicy) {
+	l.tracePolicy = policy
+}
@@ -0,0 +1,127 @@
+// Filename: internal/mailer/mailer.go
+
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	ttemplate "text/template"
+)
+
+// The email templates are embedded into the binary
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// Message holds a single rendered email
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// A Transport delivers rendered messages
+type Transport interface {
+	Send(msg *Message) error
+}
+
+// Define the mailer type
+type Mailer struct {
+	transport Transport
+	sender    string
+}
+
+// The New() function creates a new Mailer that delivers through the transport
+func New(transport Transport, sender string) Mailer {
+	return Mailer{
+		transport: transport,
+		sender:    sender,
+	}
+}
+
+// Send() renders the template file with the data and delivers it to the recipient.
+// Each template file defines a "subject", "plainBody" and "htmlBody" template.
+func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
+	// The subject and plain text body use text/template
+	tmpl, err := ttemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+	// The HTML body uses html/template so the data is escaped
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+	msg := &Message{
+		From:      m.sender,
+		To:        recipient,
+		Subject:   strings.TrimSpace(subject.String()),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}
+	return m.transport.Send(msg)
+}
+
+// Bytes() encodes the message as a multipart/alternative MIME email
+func (msg *Message) Bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	// Write the top level headers
+	fmt.Fprintf(buf, "From: %s\r\n", msg.From)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	// The plain text part goes first so clients prefer the HTML part
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", msg.PlainBody},
+		{"text/html; charset=utf-8", msg.HTMLBody},
+	}
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "8bit")
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		_, err = pw.Write([]byte(part.body))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+// Filename: internal/mailer/mailer_test.go
+
+package mailer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSender = "Schools Directory <no-reply@example.com>"
+
+func TestSendTemplates(t *testing.T) {
+	tests := []struct {
+		template string
+		data     map[string]interface{}
+		subject  string
+		want     []string //must appear in both bodies
+	}{
+		{
+			template: "user_welcome.tmpl",
+			data: map[string]interface{}{
+				"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+				"name":            "Jane <Doe>",
+				"userID":          int64(42),
+			},
+			subject: "Welcome to the Schools Directory!",
+			want:    []string{"Y3QMGX3PJ3WLRL2YRTQGQ6KRHU", "42", "PUT /v1/users/activated"},
+		},
+		{
+			template: "token_password_reset.tmpl",
+			data: map[string]interface{}{
+				"passwordResetToken": "FTT7OMGIEHQGLBSG3Z5ZCK3RKM",
+			},
+			subject: "Reset your Schools Directory password",
+			want:    []string{"FTT7OMGIEHQGLBSG3Z5ZCK3RKM", "PUT /v1/users/password"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			transport := NewMemoryTransport()
+			m := New(transport, testSender)
+			err := m.Send("jane@example.com", tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := transport.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("got %d messages; want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.From != testSender || msg.To != "jane@example.com" {
+				t.Errorf("got from %q to %q", msg.From, msg.To)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("got subject %q; want %q", msg.Subject, tt.subject)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(msg.PlainBody, want) {
+					t.Errorf("plain body does not contain %q", want)
+				}
+				if !strings.Contains(msg.HTMLBody, want) {
+					t.Errorf("HTML body does not contain %q", want)
+				}
+			}
+			if !strings.HasPrefix(strings.TrimSpace(msg.HTMLBody), "<!doctype html>") {
+				t.Errorf("HTML body is not an HTML document")
+			}
+			if strings.Contains(msg.HTMLBody, "{{") || strings.Contains(msg.PlainBody, "{{") {
+				t.Errorf("template actions were not rendered")
+			}
+		})
+	}
+}
+
+func TestSendEscapesHTMLBody(t *testing.T) {
+	transport := NewMemoryTransport()
+	err := New(transport, testSender).Send("jane@example.com", "user_welcome.tmpl", map[string]interface{}{
+		"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+		"name":            "<script>alert(1)</script>",
+		"userID":          1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := transport.Messages()[0]
+	if strings.Contains(msg.HTMLBody, "<script>") {
+		t.Error("HTML body contains an unescaped name")
+	}
+	if !strings.Contains(msg.PlainBody, "<script>alert(1)</script>") {
+		t.Error("plain body should contain the name as it is")
+	}
+}
+
+func TestSendUnknownTemplate(t *testing.T) {
+	transport := NewMemoryTransport()
+	err := New(transport, testSender).Send("jane@example.com", "missing.tmpl", nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing template")
+	}
+	if len(transport.Messages()) != 0 {
+		t.Error("a message was sent for a missing template")
+	}
+}
+
+func TestDirTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	transport, err := NewDirTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = New(transport, testSender).Send("jane@example.com", "token_password_reset.tmpl", map[string]interface{}{
+		"passwordResetToken": "FTT7OMGIEHQGLBSG3Z5ZCK3RKM",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-jane_at_example.com.eml") {
+		t.Fatalf("got files %v; want one .eml file for the recipient", files)
+	}
+	body, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"To: jane@example.com\r\n",
+		"Subject: Reset your Schools Directory password\r\n",
+		"Content-Type: multipart/alternative;",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"FTT7OMGIEHQGLBSG3Z5ZCK3RKM",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("message does not contain %q", want)
+		}
+	}
+}
+
+func TestSMTPTransportTimeout(t *testing.T) {
+	// A server that accepts connections but never says anything
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	transport := NewSMTPTransport("127.0.0.1", addr.Port, "", "")
+	transport.timeout = 100 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- transport.Send(&Message{From: testSender, To: "jane@example.com", Subject: "hi"})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error from a server that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send() did not time out")
+	}
+}
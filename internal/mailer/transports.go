@@ -0,0 +1,171 @@
+// Filename: internal/mailer/transports.go
+
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A whole SMTP exchange must finish within this long, so a hung server
+// cannot hold on to the background goroutine sending the mail
+const defaultSMTPTimeout = 10 * time.Second
+
+// SMTPTransport sends messages through an SMTP server
+type SMTPTransport struct {
+	host     string
+	port     int
+	username string
+	password string
+	timeout  time.Duration
+}
+
+// The NewSMTPTransport() function creates a transport for the SMTP server
+func NewSMTPTransport(host string, port int, username, password string) *SMTPTransport {
+	return &SMTPTransport{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		timeout:  defaultSMTPTimeout,
+	}
+}
+
+// Send() delivers the message to the SMTP server
+func (t *SMTPTransport) Send(msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	// Only authenticate if we were given credentials
+	var auth smtp.Auth
+	if t.username != "" {
+		auth = smtp.PlainAuth("", t.username, t.password, t.host)
+	}
+	addr := net.JoinHostPort(t.host, strconv.Itoa(t.port))
+	// The envelope sender must be a bare address, while the From header may
+	// include a display name such as "Schools Directory <no-reply@...>"
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	// smtp.SendMail() has no timeouts, so dial the connection ourselves and
+	// give the whole exchange a deadline
+	conn, err := net.DialTimeout("tcp", addr, t.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(t.timeout))
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, t.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	// Upgrade to TLS when the server offers it, as smtp.SendMail() does
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: t.host})
+		if err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// DirTransport writes each message to a .eml file in a directory
+type DirTransport struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+// The NewDirTransport() function creates a transport that drops messages in dir
+func NewDirTransport(dir string) (*DirTransport, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &DirTransport{dir: dir}, nil
+}
+
+// Send() writes the message to a new file
+func (t *DirTransport) Send(msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.seq++
+	seq := t.seq
+	t.mu.Unlock()
+	// Name the file after the time, a sequence number and the recipient
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().UTC().Format("20060102T150405"), seq, recipient)
+	return os.WriteFile(filepath.Join(t.dir, name), body, 0o644)
+}
+
+// MemoryTransport keeps every message in memory
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+// The NewMemoryTransport() function creates an empty in-memory transport
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+// Send() records the message
+func (t *MemoryTransport) Send(msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Messages() returns a copy of the messages sent so far
+func (t *MemoryTransport) Messages() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]*Message, len(t.messages))
+	copy(messages, t.messages)
+	return messages
+}
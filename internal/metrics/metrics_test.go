@@ -0,0 +1,120 @@
+// Filename: internal/metrics/metrics_test.go
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The expose() function writes the registry out as a string
+func expose(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() returned %d; wrote %d bytes", n, buf.Len())
+	}
+	return buf.String()
+}
+
+func TestHistogramExposition(t *testing.T) {
+	r := New()
+	// The buckets are sorted, whatever order they are given in
+	h := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "route")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 3} {
+		h.Observe(value, "/v1/schools")
+	}
+	want := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/v1/schools",le="0.1"} 2
+latency_seconds_bucket{route="/v1/schools",le="0.5"} 3
+latency_seconds_bucket{route="/v1/schools",le="1"} 4
+latency_seconds_bucket{route="/v1/schools",le="+Inf"} 5
+latency_seconds_sum{route="/v1/schools"} 4.15
+latency_seconds_count{route="/v1/schools"} 5
+`
+	if got := expose(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	r := New()
+	h := r.NewHistogramVec("size_bytes", "Sizes.", []float64{10})
+	h.Observe(20)
+	got := expose(t, r)
+	for _, want := range []string{
+		`size_bytes_bucket{le="10"} 0`,
+		`size_bytes_bucket{le="+Inf"} 1`,
+		"size_bytes_sum 20\n",
+		"size_bytes_count 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestCounterExposition(t *testing.T) {
+	r := New()
+	c := r.NewCounterVec("requests_total", "Requests\nby status.", "method", "status")
+	c.Inc("POST", "201")
+	c.Inc("GET", "200")
+	c.Add(2, "GET", "200")
+	g := r.NewGauge("in_flight", "In flight.")
+	g.Add(3)
+	g.Add(-1)
+	r.NewGaugeFunc("pool_size", "Pool size.", func() float64 { return 20 })
+	r.NewCounterFunc("waits_total", "Waits.", func() float64 { return 1.5 })
+	// Series are sorted and the help text stays on one line
+	want := `# HELP requests_total Requests by status.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 3
+requests_total{method="POST",status="201"} 1
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP pool_size Pool size.
+# TYPE pool_size gauge
+pool_size 20
+# HELP waits_total Waits.
+# TYPE waits_total counter
+waits_total 1.5
+`
+	if got := expose(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{`plain`, `plain`},
+		{`C:\schools`, `C:\\schools`},
+		{`say "hi"`, `say \"hi\"`},
+		{"two\nlines", `two\nlines`},
+	}
+	for _, tt := range tests {
+		r := New()
+		r.NewCounterVec("escaped_total", "Escaping.", "value").Inc(tt.value)
+		want := `escaped_total{value="` + tt.want + `"} 1`
+		if got := expose(t, r); !strings.Contains(got, want) {
+			t.Errorf("label %q: missing %s in\n%s", tt.value, want, got)
+		}
+	}
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want a panic for the wrong number of label values")
+		}
+	}()
+	New().NewCounterVec("requests_total", "Requests.", "method", "status").Inc("GET")
+}
@@ -48,7 +48,7 @@ func Matches(value string, rx *regexp.Regexp) bool {
 // Validwebsite() checks if a string value is a valid web url
 func ValidWebsite(website string) bool {
 	_, err := url.ParseRequestURI(website)
-	return err != nil
+	return err == nil
 }
 
 // AddError() adds an error entry to the Errors map
@@ -0,0 +1,26 @@
+// Filename: internal/validator/validator_test.go
+
+package validator
+
+import (
+	"testing"
+)
+
+func TestValidWebsite(t *testing.T) {
+	tests := []struct {
+		website string
+		want    bool
+	}{
+		{"https://www.ub.edu.bz", true},
+		{"http://sjc.edu.bz/admissions?year=2024", true},
+		{"https://example.com:8443/", true},
+		{"", false},
+		{"www.ub.edu.bz", false},
+		{"not a website", false},
+	}
+	for _, tt := range tests {
+		if got := ValidWebsite(tt.website); got != tt.want {
+			t.Errorf("ValidWebsite(%q) = %t; want %t", tt.website, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Filename: internal/xlsx/xlsx_test.go
+
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.index); got != tt.want {
+			t.Errorf("columnName(%d) = %q; want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+// The parts of the sheet XML the test looks at
+type testSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R    string `xml:"r,attr"`
+			T    string `xml:"t,attr"`
+			V    string `xml:"v"`
+			Text string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// The readParts() function unzips a workbook into its parts
+func readParts(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(content)
+	}
+	return parts
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Schools & <Co>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 30 columns, so the row goes past Z
+	wide := make([]interface{}, 30)
+	for i := range wide {
+		wide[i] = i
+	}
+	rows := [][]interface{}{
+		{"name", "count", "ratio"},
+		{`<script>&"x"</script>`, int64(42), 17.251},
+		{"  leading space", int32(-3), ""},
+		wide,
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("late"); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteRow() after Close() = %v; want ErrClosed", err)
+	}
+
+	parts := readParts(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Schools &amp; &lt;Co&gt;"`) {
+		t.Errorf("sheet name not escaped: %s", parts["xl/workbook.xml"])
+	}
+	sheetXML := parts["xl/worksheets/sheet1.xml"]
+	if strings.Contains(sheetXML, "<script>") {
+		t.Errorf("cell text not escaped: %s", sheetXML)
+	}
+	var sheet testSheet
+	if err := xml.Unmarshal([]byte(sheetXML), &sheet); err != nil {
+		t.Fatalf("sheet is not valid XML: %v", err)
+	}
+	if len(sheet.Rows) != len(rows) {
+		t.Fatalf("got %d rows; want %d", len(sheet.Rows), len(rows))
+	}
+	second := sheet.Rows[1]
+	if second.R != 2 {
+		t.Errorf("row number = %d; want 2", second.R)
+	}
+	tests := []struct {
+		got, want string
+	}{
+		{second.Cells[0].R, "A2"},
+		{second.Cells[0].T, "inlineStr"},
+		{second.Cells[0].Text, `<script>&"x"</script>`},
+		{second.Cells[1].R, "B2"},
+		{second.Cells[1].T, ""},
+		{second.Cells[1].V, "42"},
+		{second.Cells[2].V, "17.251"},
+		{sheet.Rows[2].Cells[0].Text, "  leading space"},
+		{sheet.Rows[2].Cells[1].V, "-3"},
+		{sheet.Rows[2].Cells[2].T, "inlineStr"},
+		{sheet.Rows[3].Cells[25].R, "Z4"},
+		{sheet.Rows[3].Cells[26].R, "AA4"},
+		{sheet.Rows[3].Cells[29].R, "AD4"},
+		{sheet.Rows[3].Cells[29].V, "29"},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("check %d: got %q; want %q", i, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+-- Filename: migrations/000008_add_schools_deleted_at.down.sql
+
+DELETE FROM permissions WHERE code = 'schools:admin';
+DROP INDEX IF EXISTS schools_deleted_at_idx;
+ALTER TABLE schools DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,14 @@
+-- Filename: migrations/000008_add_schools_deleted_at.up.sql
+
+ALTER TABLE schools ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS schools_deleted_at_idx ON schools (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Admins can grant permissions through POST /v1/users/:id/permissions. The
+-- first admin has to be granted by hand once they have registered:
+--
+--   INSERT INTO users_permissions
+--   SELECT users.id, permissions.id FROM users, permissions
+--   WHERE users.email = 'admin@example.com' AND permissions.code = 'schools:admin'
+--   ON CONFLICT DO NOTHING;
+INSERT INTO permissions (code)
+VALUES ('schools:admin');
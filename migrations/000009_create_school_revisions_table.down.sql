@@ -0,0 +1,3 @@
+-- Filename: migrations/000009_create_school_revisions_table.down.sql
+
+DROP TABLE IF EXISTS school_revisions;
@@ -0,0 +1,23 @@
+-- Filename: migrations/000009_create_school_revisions_table.up.sql
+
+CREATE TABLE IF NOT EXISTS school_revisions (
+    school_id bigint NOT NULL REFERENCES schools ON DELETE CASCADE,
+    version integer NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    level text NOT NULL,
+    contact text NOT NULL,
+    phone text NOT NULL,
+    email text NOT NULL,
+    website text NOT NULL,
+    address text NOT NULL,
+    mode text[] NOT NULL,
+    PRIMARY KEY (school_id, version)
+);
+
+-- Record the current state of the existing schools as their first known revision
+INSERT INTO school_revisions (school_id, version, name, level, contact, phone, email, website, address, mode)
+SELECT id, version, name, level, contact, phone, email, website, address, mode
+FROM schools
+ON CONFLICT DO NOTHING;
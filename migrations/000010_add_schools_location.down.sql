@@ -0,0 +1,9 @@
+-- Filename: migrations/000010_add_schools_location.down.sql
+
+ALTER TABLE school_revisions DROP COLUMN IF EXISTS longitude;
+ALTER TABLE school_revisions DROP COLUMN IF EXISTS latitude;
+
+DROP INDEX IF EXISTS schools_location_idx;
+ALTER TABLE schools DROP CONSTRAINT IF EXISTS location_range_check;
+ALTER TABLE schools DROP COLUMN IF EXISTS longitude;
+ALTER TABLE schools DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,12 @@
+-- Filename: migrations/000010_add_schools_location.up.sql
+
+ALTER TABLE schools ADD COLUMN IF NOT EXISTS latitude double precision;
+ALTER TABLE schools ADD COLUMN IF NOT EXISTS longitude double precision;
+ALTER TABLE schools ADD CONSTRAINT location_range_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+CREATE INDEX IF NOT EXISTS schools_location_idx ON schools (latitude, longitude) WHERE deleted_at IS NULL;
+
+ALTER TABLE school_revisions ADD COLUMN IF NOT EXISTS latitude double precision;
+ALTER TABLE school_revisions ADD COLUMN IF NOT EXISTS longitude double precision;
@@ -0,0 +1,3 @@
+-- Filename: migrations/000011_add_schools_trigram_index.down.sql
+
+DROP INDEX IF EXISTS schools_name_trgm_idx;
@@ -0,0 +1,4 @@
+-- Filename: migrations/000011_add_schools_trigram_index.up.sql
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS schools_name_trgm_idx ON schools USING GIN (name gin_trgm_ops);
@@ -0,0 +1,4 @@
+-- Filename: migrations/000012_add_schools_search_vector.down.sql
+
+DROP INDEX IF EXISTS schools_search_vector_idx;
+ALTER TABLE schools DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,8 @@
+-- Filename: migrations/000012_add_schools_search_vector.up.sql
+
+ALTER TABLE schools ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(address, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(contact, '')), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS schools_search_vector_idx ON schools USING GIN (search_vector);
@@ -0,0 +1,3 @@
+-- Filename: migrations/000013_create_school_stats_view.down.sql
+
+DROP MATERIALIZED VIEW IF EXISTS school_stats;
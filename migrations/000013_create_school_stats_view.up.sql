@@ -0,0 +1,32 @@
+-- Filename: migrations/000013_create_school_stats_view.up.sql
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS school_stats AS
+    SELECT 'total' AS dimension, '' AS value, COUNT(*) AS count, NOW() AS refreshed_at
+    FROM schools
+    WHERE deleted_at IS NULL
+UNION ALL
+    SELECT 'level', level, COUNT(*), NOW()
+    FROM schools
+    WHERE deleted_at IS NULL
+    GROUP BY level
+UNION ALL
+    SELECT 'mode', m, COUNT(*), NOW()
+    FROM schools, unnest(mode) AS m
+    WHERE deleted_at IS NULL
+    GROUP BY m
+UNION ALL
+    SELECT 'month', to_char(date_trunc('month', created_at), 'YYYY-MM'), COUNT(*), NOW()
+    FROM schools
+    WHERE deleted_at IS NULL
+    GROUP BY 2
+UNION ALL
+    SELECT 'missing', 'website', COUNT(*), NOW()
+    FROM schools
+    WHERE deleted_at IS NULL AND btrim(website) = ''
+UNION ALL
+    SELECT 'missing', 'email', COUNT(*), NOW()
+    FROM schools
+    WHERE deleted_at IS NULL AND btrim(email) = '';
+
+-- REFRESH MATERIALIZED VIEW CONCURRENTLY needs a unique index
+CREATE UNIQUE INDEX IF NOT EXISTS school_stats_dimension_value_idx ON school_stats (dimension, value);
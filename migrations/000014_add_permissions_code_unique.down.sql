@@ -0,0 +1,3 @@
+-- Filename: migrations/000014_add_permissions_code_unique.down.sql
+
+ALTER TABLE permissions DROP CONSTRAINT IF EXISTS permissions_code_key;
@@ -0,0 +1,15 @@
+-- Filename: migrations/000014_add_permissions_code_unique.up.sql
+
+-- Point grants of duplicated codes at the oldest row before removing the rest
+INSERT INTO users_permissions (user_id, permission_id)
+SELECT DISTINCT up.user_id, keep.id
+FROM users_permissions up
+INNER JOIN permissions p ON p.id = up.permission_id
+INNER JOIN (SELECT code, min(id) AS id FROM permissions GROUP BY code) keep ON keep.code = p.code
+ON CONFLICT DO NOTHING;
+
+DELETE FROM permissions p
+USING permissions keep
+WHERE p.code = keep.code AND p.id > keep.id;
+
+ALTER TABLE permissions ADD CONSTRAINT permissions_code_key UNIQUE (code);
@@ -0,0 +1,4 @@
+-- Filename: migrations/000015_add_school_revisions_action.down.sql
+
+ALTER TABLE school_revisions DROP CONSTRAINT IF EXISTS school_revisions_action_check;
+ALTER TABLE school_revisions DROP COLUMN IF EXISTS action;
@@ -0,0 +1,12 @@
+-- Filename: migrations/000015_add_school_revisions_action.up.sql
+
+-- Record what kind of change produced each revision, so deletes and restores
+-- stand out from edits in the history
+ALTER TABLE school_revisions ADD COLUMN IF NOT EXISTS action text NOT NULL DEFAULT 'update';
+ALTER TABLE school_revisions ADD CONSTRAINT school_revisions_action_check CHECK (
+    action IN ('create', 'update', 'delete', 'restore')
+);
+
+-- The first version of a school is always its creation. Deletes and restores
+-- recorded before this migration cannot be told apart and stay as updates
+UPDATE school_revisions SET action = 'create' WHERE version = 1;